@@ -0,0 +1,79 @@
+package challenge
+
+import (
+	"fmt"
+
+	"github.com/antlr4-go/antlr/v4"
+
+	generated "github.com/Azure/msi-dataplane/pkg/challenge/internal/parser"
+)
+
+// parseANTLR parses one header value with the parser generated from Challenge.g4, to check the hand-written parser
+// against. Header values are octets, so each byte is passed to ANTLR as one character.
+func parseANTLR(value string) (Challenges, []error) {
+	characters := make([]rune, len(value))
+	for i := 0; i < len(value); i++ {
+		characters[i] = rune(value[i])
+	}
+
+	parsingErrors := &errorSink{}
+	lexer := generated.NewChallengeLexer(antlr.NewInputStream(string(characters)))
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(parsingErrors)
+	tokens := antlr.NewCommonTokenStream(lexer, 0)
+	p := generated.NewChallengeParser(tokens)
+	p.RemoveErrorListeners()
+	p.AddErrorListener(parsingErrors)
+	tree := p.Header()
+	// the grammar does not end with EOF, so ANTLR stops quietly at the first character it cannot parse
+	if next := tokens.LT(1); next.GetTokenType() != antlr.TokenEOF {
+		parsingErrors.errors = append(parsingErrors.errors, fmt.Errorf("syntax error at offset %d: unexpected %q", next.GetStart(), next.GetText()))
+	}
+	if parsingErrors.errors != nil {
+		return nil, parsingErrors.errors
+	}
+
+	listener := &listener{value: value, builder: &builder{}}
+	antlr.ParseTreeWalkerDefault.Walk(listener, tree)
+	return listener.builder.challenges, listener.builder.errors
+}
+
+type listener struct {
+	value   string
+	builder *builder
+	*generated.BaseChallengeListener
+}
+
+// EnterChallenge is called when production challenge is entered.
+func (s *listener) EnterChallenge(ctx *generated.ChallengeContext) {
+	s.builder.challenge(s.text(ctx.Auth_scheme()))
+	for _, list := range ctx.AllAuth_params() {
+		for _, param := range list.AllAuth_param() {
+			if quoted := param.Auth_rhs().Quoted_string(); quoted != nil {
+				s.builder.quotedParam(s.text(param.Auth_lhs()), s.text(quoted))
+			} else {
+				s.builder.param(s.text(param.Auth_lhs()), s.text(param.Auth_rhs()))
+			}
+		}
+	}
+	for _, value := range ctx.AllToken68() {
+		s.builder.token68(s.text(value))
+	}
+	s.builder.end()
+}
+
+// text finds the octets in the header value that make up the rule.
+func (s *listener) text(ctx antlr.ParserRuleContext) string {
+	return s.value[ctx.GetStart().GetStart() : ctx.GetStop().GetStop()+1]
+}
+
+type errorSink struct {
+	*antlr.DefaultErrorListener
+	errors []error
+}
+
+func (e *errorSink) SyntaxError(recognizer antlr.Recognizer, offendingSymbol interface{}, line, column int, msg string, exception antlr.RecognitionException) {
+	e.errors = append(e.errors, fmt.Errorf("syntax error in line %d:%d: %v: %s", line, column, offendingSymbol, msg))
+}
+
+var _ antlr.ErrorListener = (*errorSink)(nil)
@@ -0,0 +1,346 @@
+package challenge
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// conformanceCases are the examples from RFC 9110 and the RFCs defining the schemes it refers to, named for the section
+// they appear in, and the WWW-Authenticate cases from the test suite at https://greenbytes.de/tech/tc/httpauth/, named
+// for the test case. Each case is one or more values of the WWW-Authenticate header and the exact challenges in them.
+// Cases where the parser knowingly departs from the RFCs are named with a known-deviation prefix.
+var conformanceCases = []struct {
+	name   string
+	values []string
+	output Challenges
+	error  bool
+}{
+	{
+		name:   "rfc9110/section-11.6.1",
+		values: []string{`Basic realm="simple", Newauth realm="apps", type=1, title="Login to \"apps\""`},
+		output: Challenges{
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "simple"}}},
+			{Scheme: "Newauth", Params: []Param{
+				{Name: "realm", Value: "apps"},
+				{Name: "type", Value: "1"},
+				{Name: "title", Value: `Login to "apps"`},
+			}},
+		},
+	},
+	{
+		name:   "rfc9110/section-11.2/token68",
+		values: []string{`Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==`},
+		output: Challenges{{Scheme: "Basic", Token68: "QWxhZGRpbjpvcGVuIHNlc2FtZQ=="}},
+	},
+	{
+		name:   "rfc9110/section-11.2/token68-punctuation",
+		values: []string{`Newauth a-b.c_d~e+f/g==`},
+		output: Challenges{{Scheme: "Newauth", Token68: "a-b.c_d~e+f/g=="}},
+	},
+	{
+		name:   "rfc9110/section-11.2/token68-comma",
+		values: []string{`Newauth a,b`},
+		output: Challenges{{Scheme: "Newauth", Token68: "a"}, {Scheme: "b"}},
+	},
+	{
+		name:   "rfc9110/section-5.6.4/quoted-pair",
+		values: []string{`Basic realm="a\b"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "ab"}}}},
+	},
+	{
+		name:   "rfc9110/section-5.6.4/quoted-pair-not-an-escape-sequence",
+		values: []string{`Basic realm="\x41"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "x41"}}}},
+	},
+	{
+		name:   "rfc9110/section-5.6.4/quoted-pair-backslash",
+		values: []string{`Basic realm="a\\b"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: `a\b`}}}},
+	},
+	{
+		name:   "rfc9110/section-5.6.4/quoted-pair-htab",
+		values: []string{"Basic realm=\"a\\\tb\""},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "a\tb"}}}},
+	},
+	{
+		name:   "rfc9110/section-5.6.4/quoted-pair-obs-text",
+		values: []string{"Basic realm=\"\\\xe4\""},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "\xe4"}}}},
+	},
+	{
+		name:   "rfc9110/section-5.6.4/obs-text",
+		values: []string{"Basic realm=\"foo-\xe4\""},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo-\xe4"}}}},
+	},
+	{
+		name:   "rfc9110/section-5.6.4/empty-quoted-string",
+		values: []string{`Basic realm=""`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: ""}}}},
+	},
+	{
+		name:   "rfc7617/section-2",
+		values: []string{`Basic realm="WallyWorld"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "WallyWorld"}}}},
+	},
+	{
+		name:   "rfc7617/section-2.1",
+		values: []string{`Basic realm="foo", charset="UTF-8"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo"}, {Name: "charset", Value: "UTF-8"}}}},
+	},
+	{
+		name:   "rfc6750/section-3",
+		values: []string{`Bearer realm="example"`},
+		output: Challenges{{Scheme: "Bearer", Params: []Param{{Name: "realm", Value: "example"}}}},
+	},
+	{
+		name:   "rfc6750/section-3/error",
+		values: []string{`Bearer realm="example", error="invalid_token", error_description="The access token expired"`},
+		output: Challenges{{Scheme: "Bearer", Params: []Param{
+			{Name: "realm", Value: "example"},
+			{Name: "error", Value: "invalid_token"},
+			{Name: "error_description", Value: "The access token expired"},
+		}}},
+	},
+	{
+		name: "rfc7616/section-3.9.1",
+		values: []string{
+			`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+			`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+		},
+		output: Challenges{
+			{Scheme: "Digest", Params: []Param{
+				{Name: "realm", Value: "http-auth@example.org"},
+				{Name: "qop", Value: "auth, auth-int"},
+				{Name: "algorithm", Value: "SHA-256"},
+				{Name: "nonce", Value: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"},
+				{Name: "opaque", Value: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"},
+			}},
+			{Scheme: "Digest", Params: []Param{
+				{Name: "realm", Value: "http-auth@example.org"},
+				{Name: "qop", Value: "auth, auth-int"},
+				{Name: "algorithm", Value: "MD5"},
+				{Name: "nonce", Value: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"},
+				{Name: "opaque", Value: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"},
+			}},
+		},
+	},
+	{
+		name:   "rfc4559/section-5",
+		values: []string{`Negotiate`},
+		output: Challenges{{Scheme: "Negotiate"}},
+	},
+	{
+		name:   "rfc4559/section-5/token68",
+		values: []string{`Negotiate a87421000492aa874209af8bc028`},
+		output: Challenges{{Scheme: "Negotiate", Token68: "a87421000492aa874209af8bc028"}},
+	},
+	{
+		name:   "httpauth/simplebasic",
+		values: []string{`Basic realm="foo"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicucase",
+		values: []string{`BASIC REALM="foo"`},
+		output: Challenges{{Scheme: "BASIC", Params: []Param{{Name: "REALM", Value: "foo"}}}},
+	},
+	{
+		name:   "httpauth/simplebasictok",
+		values: []string{`Basic realm=foo`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo"}}}},
+	},
+	{
+		name:   "httpauth/simplebasictokbs",
+		values: []string{`Basic realm=\f\o\o`},
+		error:  true,
+	},
+	{
+		name:   "httpauth/simplebasicsq",
+		values: []string{`Basic realm='foo'`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "'foo'"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicpct",
+		values: []string{`Basic realm="foo%20bar"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo%20bar"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicnorealm",
+		values: []string{`Basic`},
+		output: Challenges{{Scheme: "Basic"}},
+	},
+	{
+		// parameters may not be repeated, so the challenge is reported as invalid, with both parameters kept
+		name:   "httpauth/simplebasic2realms",
+		values: []string{`Basic realm="foo", realm="bar"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo"}, {Name: "realm", Value: "bar"}}, Err: ErrDuplicateParameter}},
+		error:  true,
+	},
+	{
+		name:   "httpauth/simplebasicwsrealm",
+		values: []string{`Basic realm = "foo"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicrealmsqc",
+		values: []string{`Basic realm="\f\o\o"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicrealmsqc2",
+		values: []string{`Basic realm="\"foo\""`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: `"foo"`}}}},
+	},
+	{
+		name:   "httpauth/simplebasicnewparam2",
+		values: []string{`Basic bar="xyz", realm="foo"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "bar", Value: "xyz"}, {Name: "realm", Value: "foo"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicrealmiso88591",
+		values: []string{"Basic realm=\"foo-\xe4\""},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo-\xe4"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicrealmutf8",
+		values: []string{"Basic realm=\"foo-\xc3\xa4\""},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "foo-\xc3\xa4"}}}},
+	},
+	{
+		name:   "httpauth/simplebasicrealmrfc2047",
+		values: []string{`Basic realm="=?ISO-8859-1?Q?foo-=E4?="`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "=?ISO-8859-1?Q?foo-=E4?="}}}},
+	},
+	{
+		name:   "httpauth/multibasicunknown",
+		values: []string{`Basic realm="basic", Newauth realm="newauth"`},
+		output: Challenges{
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "basic"}}},
+			{Scheme: "Newauth", Params: []Param{{Name: "realm", Value: "newauth"}}},
+		},
+	},
+	{
+		name:   "httpauth/multibasicunknownnoparam",
+		values: []string{`Basic realm="basic", Newauth`},
+		output: Challenges{
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "basic"}}},
+			{Scheme: "Newauth"},
+		},
+	},
+	{
+		name:   "httpauth/multibasicunknown2",
+		values: []string{`Newauth realm="newauth", Basic realm="basic"`},
+		output: Challenges{
+			{Scheme: "Newauth", Params: []Param{{Name: "realm", Value: "newauth"}}},
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "basic"}}},
+		},
+	},
+	{
+		name:   "httpauth/multibasicunknown2np",
+		values: []string{`Newauth, Basic realm="basic"`},
+		output: Challenges{
+			{Scheme: "Newauth"},
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "basic"}}},
+		},
+	},
+	{
+		name:   "httpauth/multibasicunknown2mf",
+		values: []string{`Newauth realm="newauth"`, `Basic realm="basic"`},
+		output: Challenges{
+			{Scheme: "Newauth", Params: []Param{{Name: "realm", Value: "newauth"}}},
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "basic"}}},
+		},
+	},
+	{
+		name:   "httpauth/multibasicqs",
+		values: []string{`Newauth realm="apps", type=1, title="Login to \"apps\"", Basic realm="simple"`},
+		output: Challenges{
+			{Scheme: "Newauth", Params: []Param{
+				{Name: "realm", Value: "apps"},
+				{Name: "type", Value: "1"},
+				{Name: "title", Value: `Login to "apps"`},
+			}},
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "simple"}}},
+		},
+	},
+	{
+		name:   "httpauth/multidisgscheme",
+		values: []string{`Newauth realm="Newauth Realm", basic=foo, Basic realm="Basic Realm"`},
+		output: Challenges{
+			{Scheme: "Newauth", Params: []Param{{Name: "realm", Value: "Newauth Realm"}, {Name: "basic", Value: "foo"}}},
+			{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "Basic Realm"}}},
+		},
+	},
+	{
+		name:   "httpauth/unknown",
+		values: []string{`Newauth param="value"`},
+		output: Challenges{{Scheme: "Newauth", Params: []Param{{Name: "param", Value: "value"}}}},
+	},
+	{
+		name:   "httpauth/parametersnotrequired",
+		values: []string{`A, B`},
+		output: Challenges{{Scheme: "A"}, {Scheme: "B"}},
+	},
+	{
+		name:   "httpauth/disguisedrealm",
+		values: []string{`Basic foo="realm=nottherealm", realm="basic"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "foo", Value: "realm=nottherealm"}, {Name: "realm", Value: "basic"}}}},
+	},
+	{
+		name:   "httpauth/disguisedrealm2",
+		values: []string{`Basic nottherealm="nottherealm", realm="basic"`},
+		output: Challenges{{Scheme: "Basic", Params: []Param{{Name: "nottherealm", Value: "nottherealm"}, {Name: "realm", Value: "basic"}}}},
+	},
+	{
+		name:   "httpauth/missingquote",
+		values: []string{`Basic realm="basic`},
+		error:  true,
+	},
+	// RFC 9110 section 5.6.1 requires empty list elements to be ignored, but Challenge.g4 does not accept them, so
+	// these headers fail to parse instead of holding the challenges named in the test suite.
+	{
+		name:   "known-deviation/httpauth/simplebasiccomma",
+		values: []string{`Basic , realm="foo"`},
+		error:  true,
+	},
+	{
+		name:   "known-deviation/httpauth/simplebasiccomma2",
+		values: []string{`Basic, realm="foo"`},
+		error:  true,
+	},
+	{
+		name:   "known-deviation/httpauth/simplebasicnewparam1",
+		values: []string{`Basic realm="foo", bar="xyz",, a=b,,,c=d`},
+		error:  true,
+	},
+	{
+		name:   "known-deviation/httpauth/multibasicempty",
+		values: []string{`,Basic realm="basic"`},
+		error:  true,
+	},
+}
+
+func TestConformance(t *testing.T) {
+	for _, testCase := range conformanceCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range testCase.values {
+				header.Add(WWWAuthenticate, value)
+			}
+			challenges, err := Parse(header)
+			if testCase.error && !errors.Is(err, ErrInvalidChallenge) {
+				t.Errorf("expected an invalid challenge error, got %v", err)
+			}
+			if !testCase.error && err != nil {
+				t.Errorf("expected no error and got one: %v", err)
+			}
+			if diff := cmp.Diff(testCase.output, challenges, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("invalid parse: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -53,7 +53,7 @@ auth_params: auth_param ((SP | HTAB)* COMMA (SP | HTAB)* auth_param)*;
 token68 = 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"="
 ref: https://www.rfc-editor.org/rfc/rfc9110.html#section-11.2
 */
-token68: (ALPHA | DIGIT | MINUS | COMMA | UNDERSCORE | TILDE | PLUS | SLASH)+ EQUALS*;
+token68: (ALPHA | DIGIT | MINUS | PERIOD | UNDERSCORE | TILDE | PLUS | SLASH)+ EQUALS*;
 
 /*
 auth-param = token BWS "=" BWS ( token / quoted-string )
@@ -74,7 +74,7 @@ rws: (SP | HTAB)+;
 quoted-string = DQUOTE *( qdtext / quoted-pair ) DQUOTE
 ref: https://www.rfc-editor.org/rfc/rfc9110.html#section-5.6.4
 */
-quoted_string: DQUOTE (qd_text | quoted_pair)+ DQUOTE;
+quoted_string: DQUOTE (qd_text | quoted_pair)* DQUOTE;
 
 /*
 qdtext = HTAB / SP / %x21 / %x23-5B / %x5D-7E / obs-text
@@ -1,6 +1,6 @@
 // Code generated from Challenge.g4 by ANTLR 4.13.2. DO NOT EDIT.
 
-package challenge
+package parser
 
 import (
 	"fmt"
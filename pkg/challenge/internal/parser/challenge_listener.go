@@ -1,6 +1,6 @@
 // Code generated from Challenge.g4 by ANTLR 4.13.2. DO NOT EDIT.
 
-package challenge // Challenge
+package parser // Challenge
 import "github.com/antlr4-go/antlr/v4"
 
 // ChallengeListener is a complete listener for a parse tree produced by ChallengeParser.
@@ -1,6 +1,6 @@
 // Code generated from Challenge.g4 by ANTLR 4.13.2. DO NOT EDIT.
 
-package challenge // Challenge
+package parser // Challenge
 import (
 	"fmt"
 	"strconv"
@@ -52,7 +52,7 @@ func challengeParserInit() {
 	}
 	staticData.PredictionContextCache = antlr.NewPredictionContextCache()
 	staticData.serializedATN = []int32{
-		4, 1, 37, 190, 2, 0, 7, 0, 2, 1, 7, 1, 2, 2, 7, 2, 2, 3, 7, 3, 2, 4, 7,
+		4, 1, 37, 191, 2, 0, 7, 0, 2, 1, 7, 1, 2, 2, 7, 2, 2, 3, 7, 3, 2, 4, 7,
 		4, 2, 5, 7, 5, 2, 6, 7, 6, 2, 7, 7, 7, 2, 8, 7, 8, 2, 9, 7, 9, 2, 10, 7,
 		10, 2, 11, 7, 11, 2, 12, 7, 12, 2, 13, 7, 13, 2, 14, 7, 14, 2, 15, 7, 15,
 		1, 0, 1, 0, 5, 0, 35, 8, 0, 10, 0, 12, 0, 38, 9, 0, 1, 0, 1, 0, 5, 0, 42,
@@ -64,21 +64,21 @@ func challengeParserInit() {
 		4, 12, 4, 90, 1, 4, 5, 4, 94, 8, 4, 10, 4, 12, 4, 97, 9, 4, 1, 5, 1, 5,
 		5, 5, 101, 8, 5, 10, 5, 12, 5, 104, 9, 5, 1, 5, 1, 5, 5, 5, 108, 8, 5,
 		10, 5, 12, 5, 111, 9, 5, 1, 5, 1, 5, 1, 6, 1, 6, 1, 7, 1, 7, 3, 7, 119,
-		8, 7, 1, 8, 4, 8, 122, 8, 8, 11, 8, 12, 8, 123, 1, 9, 1, 9, 1, 9, 4, 9,
-		129, 8, 9, 11, 9, 12, 9, 130, 1, 9, 1, 9, 1, 10, 1, 10, 1, 10, 1, 10, 1,
+		8, 7, 1, 8, 4, 8, 122, 8, 8, 11, 8, 12, 8, 123, 1, 9, 1, 9, 1, 9, 5, 9,
+		129, 8, 9, 10, 9, 12, 9, 132, 9, 9, 1, 9, 1, 9, 1, 10, 1, 10, 1, 10, 1,
 		10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10,
 		1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1,
-		10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 3, 10,
-		170, 8, 10, 1, 11, 1, 11, 1, 11, 1, 11, 1, 11, 3, 11, 177, 8, 11, 1, 12,
-		4, 12, 180, 8, 12, 11, 12, 12, 12, 181, 1, 13, 1, 13, 1, 14, 1, 14, 1,
-		15, 1, 15, 1, 15, 0, 0, 16, 0, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22,
-		24, 26, 28, 30, 0, 4, 1, 0, 1, 2, 5, 0, 13, 15, 17, 18, 26, 26, 31, 31,
-		36, 36, 9, 0, 3, 3, 5, 9, 12, 13, 15, 16, 18, 18, 26, 26, 30, 32, 34, 34,
-		36, 36, 1, 0, 3, 37, 228, 0, 32, 1, 0, 0, 0, 2, 52, 1, 0, 0, 0, 4, 65,
-		1, 0, 0, 0, 6, 67, 1, 0, 0, 0, 8, 88, 1, 0, 0, 0, 10, 98, 1, 0, 0, 0, 12,
-		114, 1, 0, 0, 0, 14, 118, 1, 0, 0, 0, 16, 121, 1, 0, 0, 0, 18, 125, 1,
-		0, 0, 0, 20, 169, 1, 0, 0, 0, 22, 171, 1, 0, 0, 0, 24, 179, 1, 0, 0, 0,
-		26, 183, 1, 0, 0, 0, 28, 185, 1, 0, 0, 0, 30, 187, 1, 0, 0, 0, 32, 49,
+		10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10, 1, 10,
+		3, 10, 171, 8, 10, 1, 11, 1, 11, 1, 11, 1, 11, 1, 11, 3, 11, 178, 8, 11,
+		1, 12, 4, 12, 181, 8, 12, 11, 12, 12, 12, 182, 1, 13, 1, 13, 1, 14, 1,
+		14, 1, 15, 1, 15, 1, 15, 0, 0, 16, 0, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20,
+		22, 24, 26, 28, 30, 0, 4, 1, 0, 1, 2, 5, 0, 13, 13, 15, 18, 26, 26, 31,
+		31, 36, 36, 9, 0, 3, 3, 5, 9, 12, 13, 15, 16, 18, 18, 26, 26, 30, 32, 34,
+		34, 36, 36, 1, 0, 3, 37, 229, 0, 32, 1, 0, 0, 0, 2, 52, 1, 0, 0, 0, 4,
+		65, 1, 0, 0, 0, 6, 67, 1, 0, 0, 0, 8, 88, 1, 0, 0, 0, 10, 98, 1, 0, 0,
+		0, 12, 114, 1, 0, 0, 0, 14, 118, 1, 0, 0, 0, 16, 121, 1, 0, 0, 0, 18, 125,
+		1, 0, 0, 0, 20, 170, 1, 0, 0, 0, 22, 172, 1, 0, 0, 0, 24, 180, 1, 0, 0,
+		0, 26, 184, 1, 0, 0, 0, 28, 186, 1, 0, 0, 0, 30, 188, 1, 0, 0, 0, 32, 49,
 		3, 2, 1, 0, 33, 35, 7, 0, 0, 0, 34, 33, 1, 0, 0, 0, 35, 38, 1, 0, 0, 0,
 		36, 34, 1, 0, 0, 0, 36, 37, 1, 0, 0, 0, 37, 39, 1, 0, 0, 0, 38, 36, 1,
 		0, 0, 0, 39, 43, 5, 14, 0, 0, 40, 42, 7, 0, 0, 0, 41, 40, 1, 0, 0, 0, 42,
@@ -109,41 +109,41 @@ func challengeParserInit() {
 		12, 0, 115, 13, 1, 0, 0, 0, 116, 119, 3, 24, 12, 0, 117, 119, 3, 18, 9,
 		0, 118, 116, 1, 0, 0, 0, 118, 117, 1, 0, 0, 0, 119, 15, 1, 0, 0, 0, 120,
 		122, 7, 0, 0, 0, 121, 120, 1, 0, 0, 0, 122, 123, 1, 0, 0, 0, 123, 121,
-		1, 0, 0, 0, 123, 124, 1, 0, 0, 0, 124, 17, 1, 0, 0, 0, 125, 128, 5, 4,
+		1, 0, 0, 0, 123, 124, 1, 0, 0, 0, 124, 17, 1, 0, 0, 0, 125, 130, 5, 4,
 		0, 0, 126, 129, 3, 20, 10, 0, 127, 129, 3, 22, 11, 0, 128, 126, 1, 0, 0,
-		0, 128, 127, 1, 0, 0, 0, 129, 130, 1, 0, 0, 0, 130, 128, 1, 0, 0, 0, 130,
-		131, 1, 0, 0, 0, 131, 132, 1, 0, 0, 0, 132, 133, 5, 4, 0, 0, 133, 19, 1,
-		0, 0, 0, 134, 170, 5, 1, 0, 0, 135, 170, 5, 2, 0, 0, 136, 170, 5, 3, 0,
-		0, 137, 170, 5, 5, 0, 0, 138, 170, 5, 6, 0, 0, 139, 170, 5, 7, 0, 0, 140,
-		170, 5, 8, 0, 0, 141, 170, 5, 9, 0, 0, 142, 170, 5, 10, 0, 0, 143, 170,
-		5, 11, 0, 0, 144, 170, 5, 12, 0, 0, 145, 170, 5, 13, 0, 0, 146, 170, 5,
-		14, 0, 0, 147, 170, 5, 15, 0, 0, 148, 170, 5, 16, 0, 0, 149, 170, 5, 17,
-		0, 0, 150, 170, 5, 18, 0, 0, 151, 170, 5, 19, 0, 0, 152, 170, 5, 20, 0,
-		0, 153, 170, 5, 21, 0, 0, 154, 170, 5, 22, 0, 0, 155, 170, 5, 23, 0, 0,
-		156, 170, 5, 24, 0, 0, 157, 170, 5, 25, 0, 0, 158, 170, 5, 27, 0, 0, 159,
-		170, 5, 29, 0, 0, 160, 170, 5, 30, 0, 0, 161, 170, 5, 31, 0, 0, 162, 170,
-		5, 32, 0, 0, 163, 170, 5, 26, 0, 0, 164, 170, 5, 33, 0, 0, 165, 170, 5,
-		34, 0, 0, 166, 170, 5, 35, 0, 0, 167, 170, 5, 36, 0, 0, 168, 170, 3, 30,
-		15, 0, 169, 134, 1, 0, 0, 0, 169, 135, 1, 0, 0, 0, 169, 136, 1, 0, 0, 0,
-		169, 137, 1, 0, 0, 0, 169, 138, 1, 0, 0, 0, 169, 139, 1, 0, 0, 0, 169,
-		140, 1, 0, 0, 0, 169, 141, 1, 0, 0, 0, 169, 142, 1, 0, 0, 0, 169, 143,
-		1, 0, 0, 0, 169, 144, 1, 0, 0, 0, 169, 145, 1, 0, 0, 0, 169, 146, 1, 0,
-		0, 0, 169, 147, 1, 0, 0, 0, 169, 148, 1, 0, 0, 0, 169, 149, 1, 0, 0, 0,
-		169, 150, 1, 0, 0, 0, 169, 151, 1, 0, 0, 0, 169, 152, 1, 0, 0, 0, 169,
-		153, 1, 0, 0, 0, 169, 154, 1, 0, 0, 0, 169, 155, 1, 0, 0, 0, 169, 156,
-		1, 0, 0, 0, 169, 157, 1, 0, 0, 0, 169, 158, 1, 0, 0, 0, 169, 159, 1, 0,
-		0, 0, 169, 160, 1, 0, 0, 0, 169, 161, 1, 0, 0, 0, 169, 162, 1, 0, 0, 0,
-		169, 163, 1, 0, 0, 0, 169, 164, 1, 0, 0, 0, 169, 165, 1, 0, 0, 0, 169,
-		166, 1, 0, 0, 0, 169, 167, 1, 0, 0, 0, 169, 168, 1, 0, 0, 0, 170, 21, 1,
-		0, 0, 0, 171, 176, 5, 28, 0, 0, 172, 177, 5, 1, 0, 0, 173, 177, 5, 2, 0,
-		0, 174, 177, 3, 28, 14, 0, 175, 177, 3, 30, 15, 0, 176, 172, 1, 0, 0, 0,
-		176, 173, 1, 0, 0, 0, 176, 174, 1, 0, 0, 0, 176, 175, 1, 0, 0, 0, 177,
-		23, 1, 0, 0, 0, 178, 180, 3, 26, 13, 0, 179, 178, 1, 0, 0, 0, 180, 181,
-		1, 0, 0, 0, 181, 179, 1, 0, 0, 0, 181, 182, 1, 0, 0, 0, 182, 25, 1, 0,
-		0, 0, 183, 184, 7, 2, 0, 0, 184, 27, 1, 0, 0, 0, 185, 186, 7, 3, 0, 0,
-		186, 29, 1, 0, 0, 0, 187, 188, 5, 37, 0, 0, 188, 31, 1, 0, 0, 0, 20, 36,
-		43, 49, 56, 58, 62, 71, 78, 84, 90, 95, 102, 109, 118, 123, 128, 130, 169,
-		176, 181,
+		0, 128, 127, 1, 0, 0, 0, 129, 132, 1, 0, 0, 0, 130, 128, 1, 0, 0, 0, 130,
+		131, 1, 0, 0, 0, 131, 133, 1, 0, 0, 0, 132, 130, 1, 0, 0, 0, 133, 134,
+		5, 4, 0, 0, 134, 19, 1, 0, 0, 0, 135, 171, 5, 1, 0, 0, 136, 171, 5, 2,
+		0, 0, 137, 171, 5, 3, 0, 0, 138, 171, 5, 5, 0, 0, 139, 171, 5, 6, 0, 0,
+		140, 171, 5, 7, 0, 0, 141, 171, 5, 8, 0, 0, 142, 171, 5, 9, 0, 0, 143,
+		171, 5, 10, 0, 0, 144, 171, 5, 11, 0, 0, 145, 171, 5, 12, 0, 0, 146, 171,
+		5, 13, 0, 0, 147, 171, 5, 14, 0, 0, 148, 171, 5, 15, 0, 0, 149, 171, 5,
+		16, 0, 0, 150, 171, 5, 17, 0, 0, 151, 171, 5, 18, 0, 0, 152, 171, 5, 19,
+		0, 0, 153, 171, 5, 20, 0, 0, 154, 171, 5, 21, 0, 0, 155, 171, 5, 22, 0,
+		0, 156, 171, 5, 23, 0, 0, 157, 171, 5, 24, 0, 0, 158, 171, 5, 25, 0, 0,
+		159, 171, 5, 27, 0, 0, 160, 171, 5, 29, 0, 0, 161, 171, 5, 30, 0, 0, 162,
+		171, 5, 31, 0, 0, 163, 171, 5, 32, 0, 0, 164, 171, 5, 26, 0, 0, 165, 171,
+		5, 33, 0, 0, 166, 171, 5, 34, 0, 0, 167, 171, 5, 35, 0, 0, 168, 171, 5,
+		36, 0, 0, 169, 171, 3, 30, 15, 0, 170, 135, 1, 0, 0, 0, 170, 136, 1, 0,
+		0, 0, 170, 137, 1, 0, 0, 0, 170, 138, 1, 0, 0, 0, 170, 139, 1, 0, 0, 0,
+		170, 140, 1, 0, 0, 0, 170, 141, 1, 0, 0, 0, 170, 142, 1, 0, 0, 0, 170,
+		143, 1, 0, 0, 0, 170, 144, 1, 0, 0, 0, 170, 145, 1, 0, 0, 0, 170, 146,
+		1, 0, 0, 0, 170, 147, 1, 0, 0, 0, 170, 148, 1, 0, 0, 0, 170, 149, 1, 0,
+		0, 0, 170, 150, 1, 0, 0, 0, 170, 151, 1, 0, 0, 0, 170, 152, 1, 0, 0, 0,
+		170, 153, 1, 0, 0, 0, 170, 154, 1, 0, 0, 0, 170, 155, 1, 0, 0, 0, 170,
+		156, 1, 0, 0, 0, 170, 157, 1, 0, 0, 0, 170, 158, 1, 0, 0, 0, 170, 159,
+		1, 0, 0, 0, 170, 160, 1, 0, 0, 0, 170, 161, 1, 0, 0, 0, 170, 162, 1, 0,
+		0, 0, 170, 163, 1, 0, 0, 0, 170, 164, 1, 0, 0, 0, 170, 165, 1, 0, 0, 0,
+		170, 166, 1, 0, 0, 0, 170, 167, 1, 0, 0, 0, 170, 168, 1, 0, 0, 0, 170,
+		169, 1, 0, 0, 0, 171, 21, 1, 0, 0, 0, 172, 177, 5, 28, 0, 0, 173, 178,
+		5, 1, 0, 0, 174, 178, 5, 2, 0, 0, 175, 178, 3, 28, 14, 0, 176, 178, 3,
+		30, 15, 0, 177, 173, 1, 0, 0, 0, 177, 174, 1, 0, 0, 0, 177, 175, 1, 0,
+		0, 0, 177, 176, 1, 0, 0, 0, 178, 23, 1, 0, 0, 0, 179, 181, 3, 26, 13, 0,
+		180, 179, 1, 0, 0, 0, 181, 182, 1, 0, 0, 0, 182, 180, 1, 0, 0, 0, 182,
+		183, 1, 0, 0, 0, 183, 25, 1, 0, 0, 0, 184, 185, 7, 2, 0, 0, 185, 27, 1,
+		0, 0, 0, 186, 187, 7, 3, 0, 0, 187, 29, 1, 0, 0, 0, 188, 189, 5, 37, 0,
+		0, 189, 31, 1, 0, 0, 0, 20, 36, 43, 49, 56, 58, 62, 71, 78, 84, 90, 95,
+		102, 109, 118, 123, 128, 130, 170, 177, 182,
 	}
 	deserializer := antlr.NewATNDeserializer(nil)
 	staticData.atn = deserializer.Deserialize(staticData.serializedATN)
@@ -1125,8 +1125,8 @@ type IToken68Context interface {
 	DIGIT(i int) antlr.TerminalNode
 	AllMINUS() []antlr.TerminalNode
 	MINUS(i int) antlr.TerminalNode
-	AllCOMMA() []antlr.TerminalNode
-	COMMA(i int) antlr.TerminalNode
+	AllPERIOD() []antlr.TerminalNode
+	PERIOD(i int) antlr.TerminalNode
 	AllUNDERSCORE() []antlr.TerminalNode
 	UNDERSCORE(i int) antlr.TerminalNode
 	AllTILDE() []antlr.TerminalNode
@@ -1204,12 +1204,12 @@ func (s *Token68Context) MINUS(i int) antlr.TerminalNode {
 	return s.GetToken(ChallengeParserMINUS, i)
 }
 
-func (s *Token68Context) AllCOMMA() []antlr.TerminalNode {
-	return s.GetTokens(ChallengeParserCOMMA)
+func (s *Token68Context) AllPERIOD() []antlr.TerminalNode {
+	return s.GetTokens(ChallengeParserPERIOD)
 }
 
-func (s *Token68Context) COMMA(i int) antlr.TerminalNode {
-	return s.GetToken(ChallengeParserCOMMA, i)
+func (s *Token68Context) PERIOD(i int) antlr.TerminalNode {
+	return s.GetToken(ChallengeParserPERIOD, i)
 }
 
 func (s *Token68Context) AllUNDERSCORE() []antlr.TerminalNode {
@@ -1285,7 +1285,7 @@ func (p *ChallengeParser) Token68() (localctx IToken68Context) {
 				p.SetState(87)
 				_la = p.GetTokenStream().LA(1)
 
-				if !((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&70934519808) != 0) {
+				if !((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&70934568960) != 0) {
 					p.GetErrorHandler().RecoverInline(p)
 				} else {
 					p.GetErrorHandler().ReportMatch(p)
@@ -2110,14 +2110,14 @@ func (p *ChallengeParser) Quoted_string() (localctx IQuoted_stringContext) {
 			goto errorExit
 		}
 	}
-	p.SetState(128)
+	p.SetState(130)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
 	}
 	_la = p.GetTokenStream().LA(1)
 
-	for ok := true; ok; ok = ((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&274877906926) != 0) {
+	for (int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&274877906926) != 0 {
 		p.SetState(128)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
@@ -2142,7 +2142,7 @@ func (p *ChallengeParser) Quoted_string() (localctx IQuoted_stringContext) {
 			goto errorExit
 		}
 
-		p.SetState(130)
+		p.SetState(132)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -2150,7 +2150,7 @@ func (p *ChallengeParser) Quoted_string() (localctx IQuoted_stringContext) {
 		_la = p.GetTokenStream().LA(1)
 	}
 	{
-		p.SetState(132)
+		p.SetState(133)
 		p.Match(ChallengeParserDQUOTE)
 		if p.HasError() {
 			// Recognition error - abort rule
@@ -2426,7 +2426,7 @@ func (s *Qd_textContext) ExitRule(listener antlr.ParseTreeListener) {
 func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	localctx = NewQd_textContext(p, p.GetParserRuleContext(), p.GetState())
 	p.EnterRule(localctx, 20, ChallengeParserRULE_qd_text)
-	p.SetState(169)
+	p.SetState(170)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -2436,7 +2436,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserHTAB:
 		p.EnterOuterAlt(localctx, 1)
 		{
-			p.SetState(134)
+			p.SetState(135)
 			p.Match(ChallengeParserHTAB)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2447,7 +2447,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserSP:
 		p.EnterOuterAlt(localctx, 2)
 		{
-			p.SetState(135)
+			p.SetState(136)
 			p.Match(ChallengeParserSP)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2458,7 +2458,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserEXCLAMATION_MARK:
 		p.EnterOuterAlt(localctx, 3)
 		{
-			p.SetState(136)
+			p.SetState(137)
 			p.Match(ChallengeParserEXCLAMATION_MARK)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2469,7 +2469,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserHASH:
 		p.EnterOuterAlt(localctx, 4)
 		{
-			p.SetState(137)
+			p.SetState(138)
 			p.Match(ChallengeParserHASH)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2480,7 +2480,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserDOLLAR:
 		p.EnterOuterAlt(localctx, 5)
 		{
-			p.SetState(138)
+			p.SetState(139)
 			p.Match(ChallengeParserDOLLAR)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2491,7 +2491,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserPERCENT:
 		p.EnterOuterAlt(localctx, 6)
 		{
-			p.SetState(139)
+			p.SetState(140)
 			p.Match(ChallengeParserPERCENT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2502,7 +2502,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserAMPERSAND:
 		p.EnterOuterAlt(localctx, 7)
 		{
-			p.SetState(140)
+			p.SetState(141)
 			p.Match(ChallengeParserAMPERSAND)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2513,7 +2513,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserSQUOTE:
 		p.EnterOuterAlt(localctx, 8)
 		{
-			p.SetState(141)
+			p.SetState(142)
 			p.Match(ChallengeParserSQUOTE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2524,7 +2524,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserOPEN_PARENS:
 		p.EnterOuterAlt(localctx, 9)
 		{
-			p.SetState(142)
+			p.SetState(143)
 			p.Match(ChallengeParserOPEN_PARENS)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2535,7 +2535,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserCLOSE_PARENS:
 		p.EnterOuterAlt(localctx, 10)
 		{
-			p.SetState(143)
+			p.SetState(144)
 			p.Match(ChallengeParserCLOSE_PARENS)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2546,7 +2546,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserASTERISK:
 		p.EnterOuterAlt(localctx, 11)
 		{
-			p.SetState(144)
+			p.SetState(145)
 			p.Match(ChallengeParserASTERISK)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2557,7 +2557,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserPLUS:
 		p.EnterOuterAlt(localctx, 12)
 		{
-			p.SetState(145)
+			p.SetState(146)
 			p.Match(ChallengeParserPLUS)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2568,7 +2568,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserCOMMA:
 		p.EnterOuterAlt(localctx, 13)
 		{
-			p.SetState(146)
+			p.SetState(147)
 			p.Match(ChallengeParserCOMMA)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2579,7 +2579,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserMINUS:
 		p.EnterOuterAlt(localctx, 14)
 		{
-			p.SetState(147)
+			p.SetState(148)
 			p.Match(ChallengeParserMINUS)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2590,7 +2590,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserPERIOD:
 		p.EnterOuterAlt(localctx, 15)
 		{
-			p.SetState(148)
+			p.SetState(149)
 			p.Match(ChallengeParserPERIOD)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2601,7 +2601,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserSLASH:
 		p.EnterOuterAlt(localctx, 16)
 		{
-			p.SetState(149)
+			p.SetState(150)
 			p.Match(ChallengeParserSLASH)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2612,7 +2612,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserDIGIT:
 		p.EnterOuterAlt(localctx, 17)
 		{
-			p.SetState(150)
+			p.SetState(151)
 			p.Match(ChallengeParserDIGIT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2623,7 +2623,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserCOLON:
 		p.EnterOuterAlt(localctx, 18)
 		{
-			p.SetState(151)
+			p.SetState(152)
 			p.Match(ChallengeParserCOLON)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2634,7 +2634,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserSEMICOLON:
 		p.EnterOuterAlt(localctx, 19)
 		{
-			p.SetState(152)
+			p.SetState(153)
 			p.Match(ChallengeParserSEMICOLON)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2645,7 +2645,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserLESS_THAN:
 		p.EnterOuterAlt(localctx, 20)
 		{
-			p.SetState(153)
+			p.SetState(154)
 			p.Match(ChallengeParserLESS_THAN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2656,7 +2656,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserEQUALS:
 		p.EnterOuterAlt(localctx, 21)
 		{
-			p.SetState(154)
+			p.SetState(155)
 			p.Match(ChallengeParserEQUALS)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2667,7 +2667,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserGREATER_THAN:
 		p.EnterOuterAlt(localctx, 22)
 		{
-			p.SetState(155)
+			p.SetState(156)
 			p.Match(ChallengeParserGREATER_THAN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2678,7 +2678,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserQUESTION:
 		p.EnterOuterAlt(localctx, 23)
 		{
-			p.SetState(156)
+			p.SetState(157)
 			p.Match(ChallengeParserQUESTION)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2689,7 +2689,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserAT:
 		p.EnterOuterAlt(localctx, 24)
 		{
-			p.SetState(157)
+			p.SetState(158)
 			p.Match(ChallengeParserAT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2700,7 +2700,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserOPEN_BRACKET:
 		p.EnterOuterAlt(localctx, 25)
 		{
-			p.SetState(158)
+			p.SetState(159)
 			p.Match(ChallengeParserOPEN_BRACKET)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2711,7 +2711,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserCLOSE_BRACKET:
 		p.EnterOuterAlt(localctx, 26)
 		{
-			p.SetState(159)
+			p.SetState(160)
 			p.Match(ChallengeParserCLOSE_BRACKET)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2722,7 +2722,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserCARET:
 		p.EnterOuterAlt(localctx, 27)
 		{
-			p.SetState(160)
+			p.SetState(161)
 			p.Match(ChallengeParserCARET)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2733,7 +2733,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserUNDERSCORE:
 		p.EnterOuterAlt(localctx, 28)
 		{
-			p.SetState(161)
+			p.SetState(162)
 			p.Match(ChallengeParserUNDERSCORE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2744,7 +2744,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserGRAVE:
 		p.EnterOuterAlt(localctx, 29)
 		{
-			p.SetState(162)
+			p.SetState(163)
 			p.Match(ChallengeParserGRAVE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2755,7 +2755,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserALPHA:
 		p.EnterOuterAlt(localctx, 30)
 		{
-			p.SetState(163)
+			p.SetState(164)
 			p.Match(ChallengeParserALPHA)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2766,7 +2766,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserOPEN_BRACE:
 		p.EnterOuterAlt(localctx, 31)
 		{
-			p.SetState(164)
+			p.SetState(165)
 			p.Match(ChallengeParserOPEN_BRACE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2777,7 +2777,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserPIPE:
 		p.EnterOuterAlt(localctx, 32)
 		{
-			p.SetState(165)
+			p.SetState(166)
 			p.Match(ChallengeParserPIPE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2788,7 +2788,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserCLOSE_BRACE:
 		p.EnterOuterAlt(localctx, 33)
 		{
-			p.SetState(166)
+			p.SetState(167)
 			p.Match(ChallengeParserCLOSE_BRACE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2799,7 +2799,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserTILDE:
 		p.EnterOuterAlt(localctx, 34)
 		{
-			p.SetState(167)
+			p.SetState(168)
 			p.Match(ChallengeParserTILDE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2810,7 +2810,7 @@ func (p *ChallengeParser) Qd_text() (localctx IQd_textContext) {
 	case ChallengeParserEXTENDED_ASCII:
 		p.EnterOuterAlt(localctx, 35)
 		{
-			p.SetState(168)
+			p.SetState(169)
 			p.Obs_text()
 		}
 
@@ -2951,14 +2951,14 @@ func (p *ChallengeParser) Quoted_pair() (localctx IQuoted_pairContext) {
 	p.EnterRule(localctx, 22, ChallengeParserRULE_quoted_pair)
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(171)
+		p.SetState(172)
 		p.Match(ChallengeParserBACKSLASH)
 		if p.HasError() {
 			// Recognition error - abort rule
 			goto errorExit
 		}
 	}
-	p.SetState(176)
+	p.SetState(177)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -2967,7 +2967,7 @@ func (p *ChallengeParser) Quoted_pair() (localctx IQuoted_pairContext) {
 	switch p.GetInterpreter().AdaptivePredict(p.BaseParser, p.GetTokenStream(), 18, p.GetParserRuleContext()) {
 	case 1:
 		{
-			p.SetState(172)
+			p.SetState(173)
 			p.Match(ChallengeParserHTAB)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2977,7 +2977,7 @@ func (p *ChallengeParser) Quoted_pair() (localctx IQuoted_pairContext) {
 
 	case 2:
 		{
-			p.SetState(173)
+			p.SetState(174)
 			p.Match(ChallengeParserSP)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2987,13 +2987,13 @@ func (p *ChallengeParser) Quoted_pair() (localctx IQuoted_pairContext) {
 
 	case 3:
 		{
-			p.SetState(174)
+			p.SetState(175)
 			p.Vchar()
 		}
 
 	case 4:
 		{
-			p.SetState(175)
+			p.SetState(176)
 			p.Obs_text()
 		}
 
@@ -3128,7 +3128,7 @@ func (p *ChallengeParser) Token() (localctx ITokenContext) {
 	var _la int
 
 	p.EnterOuterAlt(localctx, 1)
-	p.SetState(179)
+	p.SetState(180)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -3137,11 +3137,11 @@ func (p *ChallengeParser) Token() (localctx ITokenContext) {
 
 	for ok := true; ok; ok = ((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&93483021288) != 0) {
 		{
-			p.SetState(178)
+			p.SetState(179)
 			p.Tchar()
 		}
 
-		p.SetState(181)
+		p.SetState(182)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -3319,7 +3319,7 @@ func (p *ChallengeParser) Tchar() (localctx ITcharContext) {
 
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(183)
+		p.SetState(184)
 		_la = p.GetTokenStream().LA(1)
 
 		if !((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&93483021288) != 0) {
@@ -3590,7 +3590,7 @@ func (p *ChallengeParser) Vchar() (localctx IVcharContext) {
 
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(185)
+		p.SetState(186)
 		_la = p.GetTokenStream().LA(1)
 
 		if !((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&274877906936) != 0) {
@@ -3689,7 +3689,7 @@ func (p *ChallengeParser) Obs_text() (localctx IObs_textContext) {
 	p.EnterRule(localctx, 30, ChallengeParserRULE_obs_text)
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(187)
+		p.SetState(188)
 		p.Match(ChallengeParserEXTENDED_ASCII)
 		if p.HasError() {
 			// Recognition error - abort rule
@@ -0,0 +1,202 @@
+// Package challenge parses the authentication challenges sent by servers in the WWW-Authenticate and
+// Proxy-Authenticate headers, as specified in RFC 9110 section 11.
+package challenge
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// WWWAuthenticate is the header in which servers challenge clients to authenticate.
+	WWWAuthenticate = "WWW-Authenticate"
+	// ProxyAuthenticate is the header in which proxies challenge clients to authenticate.
+	ProxyAuthenticate = "Proxy-Authenticate"
+)
+
+var (
+	// ErrInvalidChallenge is returned when a header does not hold a valid list of challenges, or holds invalid ones.
+	ErrInvalidChallenge = errors.New("invalid authentication challenge")
+	// ErrDuplicateParameter is reported when a challenge holds more than one parameter with the same name.
+	ErrDuplicateParameter = errors.New("duplicate challenge parameter")
+	// ErrToken68AndParams is reported when a challenge holds more than one token68, or a token68 and parameters.
+	ErrToken68AndParams = errors.New("challenge has more than one token68 or parameter list")
+)
+
+// Challenge is one authentication challenge sent by a server.
+// A valid challenge holds either parameters or a token68, but not both, and each parameter at most once.
+type Challenge struct {
+	// Scheme is the authentication scheme, as sent by the server. Schemes are case-insensitive, see IsScheme.
+	Scheme string `json:"scheme"`
+	// Params holds the auth-params of the challenge in the order they were sent, with quoted values unquoted.
+	// Values hold the octets the server sent, which are not necessarily UTF-8.
+	// Parameter names are case-insensitive, see Param. Duplicate parameters are kept, and reported in Err.
+	Params []Param `json:"params,omitempty"`
+	// Token68 holds the token68 of the challenge, if it was sent one. When more than one was sent, it holds the first.
+	Token68 string `json:"token68,omitempty"`
+	// Err reports why the challenge is not valid, such as ErrDuplicateParameter, or is nil for valid challenges.
+	Err error `json:"-"`
+}
+
+// Param is one auth-param of a challenge.
+type Param struct {
+	// Name is the name of the parameter, as sent by the server.
+	Name string `json:"name"`
+	// Value is the value of the parameter.
+	Value string `json:"value"`
+}
+
+// IsScheme determines if the challenge is for the authentication scheme, ignoring case.
+func (c Challenge) IsScheme(scheme string) bool {
+	return strings.EqualFold(c.Scheme, scheme)
+}
+
+// Param looks up the value of a parameter of the challenge, ignoring the case of the name. When the parameter
+// was sent more than once, the first value is returned.
+func (c Challenge) Param(name string) (string, bool) {
+	for _, param := range c.Params {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+// Challenges are the challenges sent by a server, in the order they were sent.
+type Challenges []Challenge
+
+// ForScheme returns the first challenge for the authentication scheme, ignoring case.
+func (c Challenges) ForScheme(scheme string) (Challenge, bool) {
+	for _, challenge := range c {
+		if challenge.IsScheme(scheme) {
+			return challenge, true
+		}
+	}
+	return Challenge{}, false
+}
+
+// Parse parses the challenges in the WWW-Authenticate header.
+//
+// When the header is not a valid list of challenges, Parse returns no challenges and an ErrInvalidChallenge error.
+// When the header is valid, but some of the challenges in it are not, such as challenges with duplicate parameters,
+// Parse returns every challenge along with an ErrInvalidChallenge error that joins the errors of the invalid ones.
+// Each invalid challenge reports its own error in Err, so callers may still use the valid challenges.
+func Parse(header http.Header) (Challenges, error) {
+	return ParseHeader(header, WWWAuthenticate)
+}
+
+// ParseHeader parses the challenges in every value of the named header, such as ProxyAuthenticate, as Parse does.
+func ParseHeader(header http.Header, name string) (Challenges, error) {
+	var challenges Challenges
+	var syntaxErrs, challengeErrs []error
+	for _, value := range header.Values(name) {
+		parsed, err := parse(value)
+		if parsed == nil && err != nil {
+			syntaxErrs = append(syntaxErrs, err...)
+			continue
+		}
+		challenges = append(challenges, parsed...)
+		challengeErrs = append(challengeErrs, err...)
+	}
+	if syntaxErrs != nil {
+		return nil, collapseErrors(syntaxErrs)
+	}
+	if challengeErrs != nil {
+		return challenges, collapseErrors(challengeErrs)
+	}
+	return challenges, nil
+}
+
+// ParseString parses the challenges in one header value, as Parse does.
+func ParseString(value string) (Challenges, error) {
+	challenges, errs := parse(value)
+	if errs != nil {
+		return challenges, collapseErrors(errs)
+	}
+	return challenges, nil
+}
+
+// parse parses the challenges in one header value. Syntax errors are returned without challenges, while the
+// errors of invalid challenges are returned along with every challenge.
+func parse(value string) (Challenges, []error) {
+	var p parser
+	if err := p.parse(value); err != nil {
+		return nil, []error{err}
+	}
+	return p.builder.challenges, p.builder.errors
+}
+
+// builder assembles challenges from their parts, as they are parsed.
+type builder struct {
+	challenges Challenges
+	errors     []error
+
+	current  Challenge
+	token68s int
+}
+
+// challenge starts a new challenge for the scheme.
+func (b *builder) challenge(scheme string) {
+	b.current = Challenge{Scheme: scheme}
+	b.token68s = 0
+}
+
+// invalid records the first reason the current challenge is not valid.
+func (b *builder) invalid(err error) {
+	if b.current.Err == nil {
+		b.current.Err = err
+	}
+}
+
+// param adds a parameter to the current challenge.
+func (b *builder) param(name, value string) {
+	if _, duplicate := b.current.Param(name); duplicate {
+		b.invalid(fmt.Errorf("%w: challenge for %s has more than one %s parameter", ErrDuplicateParameter, b.current.Scheme, name))
+	}
+	b.current.Params = append(b.current.Params, Param{Name: name, Value: value})
+}
+
+// quotedParam adds a parameter with a quoted-string value to the current challenge.
+func (b *builder) quotedParam(name, value string) {
+	b.param(name, unquote(value))
+}
+
+// token68 sets the token68 of the current challenge.
+func (b *builder) token68(value string) {
+	b.token68s++
+	if b.token68s == 1 {
+		b.current.Token68 = value
+	}
+}
+
+// end finishes the current challenge.
+func (b *builder) end() {
+	if b.token68s > 1 || (b.token68s == 1 && len(b.current.Params) > 0) {
+		b.invalid(fmt.Errorf("%w: challenge for %s", ErrToken68AndParams, b.current.Scheme))
+	}
+	if b.current.Err != nil {
+		b.errors = append(b.errors, b.current.Err)
+	}
+	b.challenges = append(b.challenges, b.current)
+}
+
+func collapseErrors(errors []error) error {
+	return fmt.Errorf("%w: parsing failed: %w", ErrInvalidChallenge, parseErrors(errors))
+}
+
+// parseErrors are all the errors found parsing a header, so that callers can match any of them.
+type parseErrors []error
+
+func (e parseErrors) Error() string {
+	var reasons []string
+	for _, err := range e {
+		reasons = append(reasons, err.Error())
+	}
+	return strings.Join(reasons, ",")
+}
+
+func (e parseErrors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,365 @@
+package challenge
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// equateErrorMessages compares the errors of challenges from different parses, which are equal but not the same.
+var equateErrorMessages = cmp.Comparer(func(x, y error) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	return x.Error() == y.Error()
+})
+
+func TestParse(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		input  http.Header
+		output Challenges
+		error  bool
+	}{
+		{
+			name:   "empty",
+			input:  http.Header{},
+			output: nil,
+		},
+		{
+			name: "only a scheme",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{`Basic`},
+			},
+			output: Challenges{
+				{Scheme: "Basic"},
+			},
+		},
+		{
+			name: "one scheme, some params",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{`Basic realm="Dev", charset="UTF-8"`},
+			},
+			output: Challenges{
+				{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "Dev"}, {Name: "charset", Value: "UTF-8"}}},
+			},
+		},
+		{
+			name: "one scheme, some params",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`},
+			},
+			output: Challenges{
+				{Scheme: "Digest", Params: []Param{
+					{Name: "realm", Value: "http-auth@example.org"},
+					{Name: "qop", Value: "auth, auth-int"},
+					{Name: "algorithm", Value: "SHA-256"},
+					{Name: "nonce", Value: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"},
+					{Name: "opaque", Value: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"},
+				}},
+			},
+		},
+		{
+			name: "one scheme, multiple headers",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{
+					`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+					`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+				},
+			},
+			output: Challenges{
+				{Scheme: "Digest", Params: []Param{
+					{Name: "realm", Value: "http-auth@example.org"},
+					{Name: "qop", Value: "auth, auth-int"},
+					{Name: "algorithm", Value: "SHA-256"},
+					{Name: "nonce", Value: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"},
+					{Name: "opaque", Value: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"},
+				}},
+				{Scheme: "Digest", Params: []Param{
+					{Name: "realm", Value: "http-auth@example.org"},
+					{Name: "qop", Value: "auth, auth-int"},
+					{Name: "algorithm", Value: "MD5"},
+					{Name: "nonce", Value: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"},
+					{Name: "opaque", Value: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"},
+				}},
+			},
+		},
+		{
+			name: "many schemes, one header",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{
+					`Basic realm="simple", Newauth realm="apps", type=1, title="Login to \"apps\""`,
+				},
+			},
+			output: Challenges{
+				{Scheme: "Basic", Params: []Param{
+					{Name: "realm", Value: "simple"},
+				}},
+				{Scheme: "Newauth", Params: []Param{
+					{Name: "realm", Value: "apps"},
+					{Name: "type", Value: "1"},
+					{Name: "title", Value: `Login to "apps"`},
+				}},
+			},
+		},
+		{
+			name: "token68",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{`Negotiate YIIFjwYGKwYB+/==`},
+			},
+			output: Challenges{
+				{Scheme: "Negotiate", Token68: "YIIFjwYGKwYB+/=="},
+			},
+		},
+		{
+			name: "token68 and parameters",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{`Negotiate YIIFjwYGKwYB+/== realm="simple"`},
+			},
+			output: Challenges{
+				{Scheme: "Negotiate", Params: []Param{{Name: "realm", Value: "simple"}}, Token68: "YIIFjwYGKwYB+/==", Err: ErrToken68AndParams},
+			},
+			error: true,
+		},
+		{
+			name: "duplicate parameters",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{`Basic realm="simple", Realm="other", Bearer realm="bearer"`},
+			},
+			output: Challenges{
+				{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "simple"}, {Name: "Realm", Value: "other"}}, Err: ErrDuplicateParameter},
+				{Scheme: "Bearer", Params: []Param{{Name: "realm", Value: "bearer"}}},
+			},
+			error: true,
+		},
+		{
+			name: "other headers are ignored",
+			input: http.Header{
+				http.CanonicalHeaderKey("Proxy-Authenticate"): []string{`Basic realm="proxy"`},
+			},
+			output: nil,
+		},
+		{
+			name: "invalid content",
+			input: http.Header{
+				http.CanonicalHeaderKey("WWW-Authenticate"): []string{
+					`\b`,
+				},
+			},
+			output: nil,
+			error:  true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			challenges, err := Parse(testCase.input)
+			if testCase.error && err == nil {
+				t.Errorf("expected an error and got none")
+			}
+			if !testCase.error && err != nil {
+				t.Errorf("expected no error and got one: %v", err)
+			}
+			got, want := challenges, testCase.output
+			if diff := cmp.Diff(want, got, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("invalid parse: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	header := http.Header{}
+	header.Add(WWWAuthenticate, `Bearer realm="server"`)
+	header.Add(ProxyAuthenticate, `Basic realm="proxy"`)
+
+	challenges, err := ParseHeader(header, ProxyAuthenticate)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(Challenges{{Scheme: "Basic", Params: []Param{{Name: "realm", Value: "proxy"}}}}, challenges); diff != "" {
+		t.Errorf("invalid parse: -want, +got:\n%s", diff)
+	}
+}
+
+func TestParseString(t *testing.T) {
+	challenges, err := ParseString(`Basic realm="simple", BEARER Authorization="https://login.microsoftonline.com/tenant"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bearer, found := challenges.ForScheme("Bearer")
+	if !found {
+		t.Fatalf("expected to find a bearer challenge in %v", challenges)
+	}
+	if !bearer.IsScheme("bearer") {
+		t.Errorf("expected the scheme to match regardless of case, got %q", bearer.Scheme)
+	}
+	if value, ok := bearer.Param("authorization"); !ok || value != "https://login.microsoftonline.com/tenant" {
+		t.Errorf("unexpected authorization parameter %q, found: %v", value, ok)
+	}
+	if _, found := challenges.ForScheme("Digest"); found {
+		t.Errorf("expected no digest challenge")
+	}
+
+	if _, err := ParseString(`\b`); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("expected an invalid challenge error, got %v", err)
+	}
+}
+
+func TestParseDuplicateParameters(t *testing.T) {
+	for _, value := range []string{
+		`Bearer authorization="a", authorization="b"`,
+		`Bearer authorization="a", AUTHORIZATION="b"`,
+	} {
+		challenges, err := ParseString(value)
+		if !errors.Is(err, ErrDuplicateParameter) {
+			t.Errorf("%s: expected a duplicate parameter error, got %v", value, err)
+		}
+		if len(challenges) != 1 {
+			t.Fatalf("%s: expected the challenge to be returned, got %v", value, challenges)
+		}
+		if !errors.Is(challenges[0].Err, ErrDuplicateParameter) {
+			t.Errorf("%s: expected the challenge to report the duplicate parameter, got %v", value, challenges[0].Err)
+		}
+		if len(challenges[0].Params) != 2 {
+			t.Errorf("%s: expected both parameters to be kept, got %v", value, challenges[0].Params)
+		}
+		if authorization, _ := challenges[0].Param("authorization"); authorization != "a" {
+			t.Errorf("%s: expected the first value to be looked up, got %q", value, authorization)
+		}
+	}
+}
+
+// tchars are the characters in a token, from RFC 9110 section 5.6.2.
+const tchars = "!#$%&'*+-.^_`|~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// token68Chars are the characters in a token68 before its padding, as Challenge.g4 defines them.
+const token68Chars = "-._~+/0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func conformanceValues() []string {
+	var values []string
+	for _, testCase := range conformanceCases {
+		values = append(values, testCase.values...)
+	}
+	return values
+}
+
+// format writes challenges back into a header value, quoting every parameter value.
+func format(challenges Challenges) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	var values []string
+	for _, challenge := range challenges {
+		value := challenge.Scheme
+		switch {
+		case challenge.Token68 != "":
+			value += " " + challenge.Token68
+		case len(challenge.Params) > 0:
+			var params []string
+			for _, param := range challenge.Params {
+				params = append(params, param.Name+`="`+escaper.Replace(param.Value)+`"`)
+			}
+			value += " " + strings.Join(params, ", ")
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, ", ")
+}
+
+// FuzzParse checks that whatever a server sends, Parse either fails with an invalid challenge error or returns
+// well-formed challenges, of which the valid ones parse the same when they are written back into a header.
+func FuzzParse(f *testing.F) {
+	for _, value := range conformanceValues() {
+		f.Add(value)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		header := http.Header{}
+		header.Add(WWWAuthenticate, value)
+		challenges, err := Parse(header)
+		if err != nil && !errors.Is(err, ErrInvalidChallenge) {
+			t.Errorf("%q: expected an invalid challenge error, got %v", value, err)
+		}
+		if challenges == nil {
+			if err == nil {
+				t.Errorf("%q: expected challenges or an error, got neither", value)
+			}
+			return
+		}
+		invalid := slices.ContainsFunc(challenges, func(challenge Challenge) bool { return challenge.Err != nil })
+		if (err != nil) != invalid {
+			t.Errorf("%q: expected an error exactly when a challenge is invalid, got %v", value, err)
+		}
+
+		var valid Challenges
+		for _, challenge := range challenges {
+			if challenge.Scheme == "" || strings.Trim(challenge.Scheme, tchars) != "" {
+				t.Errorf("%q: scheme %q is not a token", value, challenge.Scheme)
+			}
+			if body := strings.TrimRight(challenge.Token68, "="); challenge.Token68 != "" && (body == "" || strings.Trim(body, token68Chars) != "") {
+				t.Errorf("%q: %q is not a token68", value, challenge.Token68)
+			}
+			for _, param := range challenge.Params {
+				if param.Name == "" || strings.Trim(param.Name, tchars) != "" {
+					t.Errorf("%q: parameter name %q is not a token", value, param.Name)
+				}
+			}
+			if challenge.Err != nil {
+				continue
+			}
+			valid = append(valid, challenge)
+			if challenge.Token68 != "" && len(challenge.Params) > 0 {
+				t.Errorf("%q: valid challenge %v has both a token68 and parameters", value, challenge)
+			}
+			for i, param := range challenge.Params {
+				for _, other := range challenge.Params[:i] {
+					if strings.EqualFold(other.Name, param.Name) {
+						t.Errorf("%q: parameter %q is duplicated in a valid challenge", value, param.Name)
+					}
+				}
+			}
+		}
+
+		if valid == nil {
+			return
+		}
+		formatted := format(valid)
+		reparsed, err := ParseString(formatted)
+		if err != nil {
+			t.Fatalf("%q: failed to parse the challenges written back as %q: %v", value, formatted, err)
+		}
+		if diff := cmp.Diff(valid, reparsed); diff != "" {
+			t.Errorf("%q: invalid parse of the challenges written back as %q: -want, +got:\n%s", value, formatted, diff)
+		}
+	})
+}
+
+// FuzzParseHeader checks that the challenges in a header with many values are the challenges in each of them.
+func FuzzParseHeader(f *testing.F) {
+	values := conformanceValues()
+	for i := range values {
+		f.Add(values[i], values[(i+1)%len(values)])
+	}
+	f.Fuzz(func(t *testing.T, first, second string) {
+		header := http.Header{}
+		header.Add(WWWAuthenticate, first)
+		header.Add(WWWAuthenticate, second)
+		challenges, err := Parse(header)
+
+		firstChallenges, firstErr := ParseString(first)
+		secondChallenges, secondErr := ParseString(second)
+		if (err != nil) != (firstErr != nil || secondErr != nil) {
+			t.Fatalf("%q, %q: expected errors %v and %v, got %v", first, second, firstErr, secondErr, err)
+		}
+		var want Challenges
+		// a value that is not a list of challenges fails the whole header
+		if (firstChallenges != nil || firstErr == nil) && (secondChallenges != nil || secondErr == nil) {
+			want = append(firstChallenges, secondChallenges...)
+		}
+		if diff := cmp.Diff(want, challenges, equateErrorMessages); diff != "" {
+			t.Errorf("%q, %q: invalid parse: -want, +got:\n%s", first, second, diff)
+		}
+	})
+}
@@ -0,0 +1,382 @@
+package challenge
+
+import (
+	"fmt"
+)
+
+// class is a set of character classes used by the grammar.
+type class uint8
+
+const (
+	classTchar class = 1 << iota
+	classToken68
+	classQDText
+	classQuotedPair
+	classWhitespace
+)
+
+// classes holds the character classes of each octet.
+var classes = func() [256]class {
+	var classes [256]class
+	for c := 0; c < 256; c++ {
+		alphanumeric := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+		visible := 0x21 <= c && c <= 0x7e
+		obsText := 0x80 <= c
+		if alphanumeric || c < 0x80 && contains("!#$%&'*+-.^_`|~", byte(c)) {
+			classes[c] |= classTchar
+		}
+		if alphanumeric || contains("-._~+/", byte(c)) {
+			classes[c] |= classToken68
+		}
+		if c == '\t' || c == ' ' || obsText || visible && c != '"' && c != '\\' {
+			classes[c] |= classQDText
+		}
+		if c == '\t' || c == ' ' || visible || obsText {
+			classes[c] |= classQuotedPair
+		}
+		if c == '\t' || c == ' ' {
+			classes[c] |= classWhitespace
+		}
+	}
+	return classes
+}()
+
+func contains(set string, c byte) bool {
+	for i := 0; i < len(set); i++ {
+		if set[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+// parser parses one header value by recursive descent, with a method for each rule in internal/parser/Challenge.g4,
+// and passes the parts of each challenge to its builder.
+//
+// The grammar is ambiguous: a token68 may end in the same padding that separates the name of a parameter from its
+// value, as in "a= b", and the space after a scheme may start a token68, parameters or nothing at all. ANTLR settles
+// each ambiguity by taking the first alternative from which the rest of the header can still be parsed. To make the
+// same choices, each method parses the rest of the header after its rule as well, trying its alternatives in order
+// and backtracking when the rest does not parse. The rest of the header only depends on the loop the parser is in and
+// its position, so the loops remember the positions they failed at and backtracking never parses the same part of the
+// header twice in the same way.
+type parser struct {
+	input string
+	// events holds the parts of the challenges on the way to the current position, which are passed to the builder
+	// once the whole header has been parsed, and failed the loops that failed at each position; headers are short,
+	// so both are kept in the parser itself, on the stack, unless the header is too long for them to fit
+	events     [16]event
+	moreEvents []event
+	numEvents  int
+	failed     [256]loops
+	moreFailed []loops
+	// furthest is the length of the longest prefix of the input that could be parsed, and quoteStart the start of
+	// the quoted string the input ended in, if any, to report syntax errors
+	furthest   int
+	quoteStart int
+
+	builder builder
+}
+
+// event is a part of a challenge found in the input, as the range of octets it spans.
+type event struct {
+	kind       eventKind
+	start, end int
+	// value holds the range of octets of the value of a parameter
+	valueStart, valueEnd int
+}
+
+type eventKind uint8
+
+const (
+	eventChallenge eventKind = iota
+	eventToken68
+	eventParam
+	eventQuotedParam
+	eventEnd
+)
+
+// loops is a set of loops in the grammar.
+type loops uint8
+
+const (
+	loopHeader loops = 1 << iota
+	loopChallenge
+	loopParams
+)
+
+// parse parses the input and passes what it finds to the builder, or returns a syntax error.
+func (p *parser) parse(input string) error {
+	p.input = input
+	if len(input)+1 > len(p.failed) {
+		p.moreFailed = make([]loops, len(input)+1)
+	}
+	p.quoteStart = -1
+
+	if !p.header() {
+		return p.syntaxError()
+	}
+	events := p.events[:min(p.numEvents, len(p.events))]
+	if p.moreEvents != nil {
+		events = p.moreEvents[:p.numEvents]
+	}
+	for _, e := range events {
+		switch e.kind {
+		case eventChallenge:
+			p.builder.challenge(input[e.start:e.end])
+		case eventToken68:
+			p.builder.token68(input[e.start:e.end])
+		case eventParam:
+			p.builder.param(input[e.start:e.end], input[e.valueStart:e.valueEnd])
+		case eventQuotedParam:
+			p.builder.quotedParam(input[e.start:e.end], input[e.valueStart:e.valueEnd])
+		case eventEnd:
+			p.builder.end()
+		}
+	}
+	return nil
+}
+
+// syntaxError reports the first character at which the input can no longer be parsed.
+func (p *parser) syntaxError() error {
+	switch {
+	case p.furthest < len(p.input):
+		return fmt.Errorf("syntax error at offset %d: unexpected %q", p.furthest, p.input[p.furthest])
+	case p.quoteStart >= 0:
+		return fmt.Errorf("syntax error at offset %d: unterminated quoted string at offset %d", len(p.input), p.quoteStart)
+	default:
+		return fmt.Errorf("syntax error at offset %d: unexpected end of header", len(p.input))
+	}
+}
+
+// emit records a part of a challenge, returning the number of events before it to backtrack to.
+func (p *parser) emit(e event) int {
+	switch {
+	case p.moreEvents != nil:
+		p.moreEvents = append(p.moreEvents[:p.numEvents], e)
+	case p.numEvents == len(p.events):
+		p.moreEvents = append(append(make([]event, 0, 2*len(p.events)), p.events[:]...), e)
+	default:
+		p.events[p.numEvents] = e
+	}
+	p.numEvents++
+	return p.numEvents - 1
+}
+
+// backtrack forgets the events after the mark, as the alternative that produced them did not parse.
+func (p *parser) backtrack(mark int) {
+	p.numEvents = mark
+}
+
+// hasFailed determines if the loop has already failed to parse the rest of the header from the position.
+func (p *parser) hasFailed(loop loops, pos int) bool {
+	if p.moreFailed != nil {
+		return p.moreFailed[pos]&loop != 0
+	}
+	return p.failed[pos]&loop != 0
+}
+
+// fail records that the loop failed to parse the rest of the header from the position.
+func (p *parser) fail(loop loops, pos int) {
+	if p.moreFailed != nil {
+		p.moreFailed[pos] |= loop
+		return
+	}
+	p.failed[pos] |= loop
+}
+
+// header: challenge ((SP | HTAB)* COMMA (SP | HTAB)* challenge)*
+func (p *parser) header() bool {
+	return p.challenge(0)
+}
+
+// headerLoop parses the rest of the header from the loop in header.
+func (p *parser) headerLoop(pos int) bool {
+	if p.hasFailed(loopHeader, pos) {
+		return false
+	}
+	if next := p.separator(pos); next >= 0 && p.challenge(next) {
+		return true
+	}
+	// the grammar does not end with EOF, but the whole header has to be parsed
+	if pos == len(p.input) {
+		return true
+	}
+	p.fail(loopHeader, pos)
+	return false
+}
+
+// challenge: auth_scheme (SP (token68 | auth_params?))*
+func (p *parser) challenge(pos int) bool {
+	// auth_scheme: token
+	end := p.token(pos)
+	if end < 0 {
+		return false
+	}
+	mark := p.emit(event{kind: eventChallenge, start: pos, end: end})
+	if p.challengeLoop(end) {
+		return true
+	}
+	p.backtrack(mark)
+	return false
+}
+
+// challengeLoop parses the rest of the header from the loop in challenge.
+func (p *parser) challengeLoop(pos int) bool {
+	if p.hasFailed(loopChallenge, pos) {
+		return false
+	}
+	if p.match(pos, ' ') && (p.token68(pos+1) || p.authParams(pos+1) || p.challengeLoop(pos+1)) {
+		return true
+	}
+	mark := p.emit(event{kind: eventEnd})
+	if p.headerLoop(pos) {
+		return true
+	}
+	p.backtrack(mark)
+	p.fail(loopChallenge, pos)
+	return false
+}
+
+// token68: (ALPHA | DIGIT | MINUS | PERIOD | UNDERSCORE | TILDE | PLUS | SLASH)+ EQUALS*
+func (p *parser) token68(pos int) bool {
+	// nothing that may follow a token68 can start with one of its characters, so only the longest one can parse
+	end := p.span(pos, classToken68)
+	if end == pos {
+		return false
+	}
+	for p.match(end, '=') {
+		end++
+	}
+	mark := p.emit(event{kind: eventToken68, start: pos, end: end})
+	if p.challengeLoop(end) {
+		return true
+	}
+	p.backtrack(mark)
+	return false
+}
+
+// auth_params: auth_param ((SP | HTAB)* COMMA (SP | HTAB)* auth_param)*
+func (p *parser) authParams(pos int) bool {
+	return p.authParam(pos)
+}
+
+// paramsLoop parses the rest of the header from the loop in auth_params.
+func (p *parser) paramsLoop(pos int) bool {
+	if p.hasFailed(loopParams, pos) {
+		return false
+	}
+	if next := p.separator(pos); next >= 0 && p.authParam(next) {
+		return true
+	}
+	if p.challengeLoop(pos) {
+		return true
+	}
+	p.fail(loopParams, pos)
+	return false
+}
+
+// auth_param: auth_lhs (SP | HTAB)* EQUALS (SP | HTAB)* (auth_rhs)
+func (p *parser) authParam(pos int) bool {
+	// auth_lhs: token
+	nameEnd := p.token(pos)
+	if nameEnd < 0 {
+		return false
+	}
+	equals := p.span(nameEnd, classWhitespace)
+	if !p.match(equals, '=') {
+		return false
+	}
+	valueStart := p.span(equals+1, classWhitespace)
+	// auth_rhs: token | quoted_string
+	kind, valueEnd := eventParam, p.token(valueStart)
+	if valueEnd < 0 {
+		kind, valueEnd = eventQuotedParam, p.quotedString(valueStart)
+	}
+	if valueEnd < 0 {
+		return false
+	}
+	mark := p.emit(event{kind: kind, start: pos, end: nameEnd, valueStart: valueStart, valueEnd: valueEnd})
+	if p.paramsLoop(valueEnd) {
+		return true
+	}
+	p.backtrack(mark)
+	return false
+}
+
+// quoted_string: DQUOTE (qd_text | quoted_pair)* DQUOTE
+func (p *parser) quotedString(pos int) int {
+	if !p.match(pos, '"') {
+		return -1
+	}
+	end := pos + 1
+	for {
+		switch {
+		case p.matchClass(end, classQDText):
+			end++
+		// quoted_pair: BACKSLASH (HTAB | SP | vchar | obs_text)
+		case p.match(end, '\\'):
+			if !p.matchClass(end+1, classQuotedPair) {
+				p.unterminated(end+1, pos)
+				return -1
+			}
+			end += 2
+		case p.match(end, '"'):
+			return end + 1
+		default:
+			p.unterminated(end, pos)
+			return -1
+		}
+	}
+}
+
+// unterminated notes the start of a quoted string that runs to the end of the input.
+func (p *parser) unterminated(end, start int) {
+	if end == len(p.input) {
+		p.quoteStart = start
+	}
+}
+
+// token: tchar+
+func (p *parser) token(pos int) int {
+	end := p.span(pos, classTchar)
+	if end == pos {
+		return -1
+	}
+	return end
+}
+
+// separator: (SP | HTAB)* COMMA (SP | HTAB)*
+func (p *parser) separator(pos int) int {
+	comma := p.span(pos, classWhitespace)
+	if !p.match(comma, ',') {
+		return -1
+	}
+	return p.span(comma+1, classWhitespace)
+}
+
+// span matches as many characters in the class as there are from the position, returning the position after them.
+func (p *parser) span(pos int, c class) int {
+	for p.matchClass(pos, c) {
+		pos++
+	}
+	return pos
+}
+
+// match matches the character at the position.
+func (p *parser) match(pos int, c byte) bool {
+	return p.matched(pos, pos < len(p.input) && p.input[pos] == c)
+}
+
+// matchClass matches a character in the class at the position.
+func (p *parser) matchClass(pos int, c class) bool {
+	return p.matched(pos, pos < len(p.input) && classes[p.input[pos]]&c != 0)
+}
+
+// matched keeps track of the longest prefix of the input that could be parsed, for syntax errors.
+func (p *parser) matched(pos int, ok bool) bool {
+	if ok && pos+1 > p.furthest {
+		p.furthest = pos + 1
+	}
+	return ok
+}
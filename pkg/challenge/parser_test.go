@@ -0,0 +1,70 @@
+package challenge
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var parserSeeds = []string{
+	`Basic`,
+	`Basic realm="Dev", charset="UTF-8"`,
+	`Basic realm="simple", Newauth realm="apps", type=1, title="Login to \"apps\""`,
+	`Bearer authorization="https://login.microsoftonline.com/tenant", resource="https://management.azure.com/"`,
+	`Negotiate YIIFjwYGKwYB+/==`,
+	`Basic abc, Bearer x=y`,
+	`Basic abc , Bearer`,
+	`Basic realm=x , Bearer`,
+	`Basic realm=x ]`,
+	`Basic  realm=x  a=b`,
+	`Basic a = b`,
+	`Bearer a= b`,
+	`Bearer abc=`,
+	`Basic abc=, def=`,
+	`Basic a=b,`,
+	"Basic\t",
+	"Basic \xe9",
+	`Basic a="\é"`,
+	`\b`,
+	``,
+}
+
+// FuzzParseMatchesANTLR checks that the hand-written parser accepts the same headers as the parser generated from
+// Challenge.g4, and finds the same challenges in them.
+func FuzzParseMatchesANTLR(f *testing.F) {
+	for _, seed := range append(parserSeeds, conformanceValues()...) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		got, gotErrs := parse(value)
+		want, wantErrs := parseANTLR(value)
+		if (gotErrs != nil) != (wantErrs != nil) {
+			t.Fatalf("%q: expected errors %v, got %v", value, wantErrs, gotErrs)
+		}
+		if diff := cmp.Diff(want, got, equateErrorMessages); diff != "" {
+			t.Errorf("%q: invalid parse: -want, +got:\n%s", value, diff)
+		}
+	})
+}
+
+// BenchmarkParse compares the parsers on a typical challenge; the hand-written parser allocates only the challenges
+// it returns.
+func BenchmarkParse(b *testing.B) {
+	const value = `Bearer authorization="https://login.microsoftonline.com/72f988bf-86f1-41af-91ab-2d7cd011db47", resource="https://management.azure.com/"`
+	for _, implementation := range []struct {
+		name  string
+		parse func(string) (Challenges, []error)
+	}{
+		{name: "hand-written", parse: parse},
+		{name: "antlr", parse: parseANTLR},
+	} {
+		b.Run(implementation.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, errs := implementation.parse(value); errs != nil {
+					b.Fatalf("unexpected errors: %v", errs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package challenge
+
+// unquote decodes a quoted-string, as specified in RFC 9110 section 5.6.4. The parser has already checked that the
+// value is one, so a quoted-pair stands for the octet after the backslash, whatever it is, and every other octet
+// between the quotes stands for itself. Octets are kept as they were sent, including obs-text, so the value is not
+// necessarily UTF-8.
+func unquote(value string) string {
+	value = value[1 : len(value)-1]
+	// values without quoted-pairs are returned as they are, without copying them
+	var decoded []byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '\\' {
+			if decoded == nil {
+				decoded = make([]byte, 0, len(value))
+				decoded = append(decoded, value[:i]...)
+			}
+			i++
+			c = value[i]
+		}
+		if decoded != nil {
+			decoded = append(decoded, c)
+		}
+	}
+	if decoded == nil {
+		return value
+	}
+	return string(decoded)
+}
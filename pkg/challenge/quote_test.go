@@ -0,0 +1,121 @@
+package challenge
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnquote(t *testing.T) {
+	for _, testCase := range []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			name:   "plain",
+			input:  `"simple"`,
+			output: "simple",
+		},
+		{
+			name:   "empty",
+			input:  `""`,
+			output: "",
+		},
+		{
+			name:   "whitespace and separators",
+			input:  "\"a, b\t=c\"",
+			output: "a, b\t=c",
+		},
+		{
+			name:   "quoted-pairs are the octet after the backslash",
+			input:  `"\a\b\n\x41\u00e4"`,
+			output: "abnx41u00e4",
+		},
+		{
+			name:   "escaped quotes and backslashes",
+			input:  `"Login to \"apps\" \\ here"`,
+			output: `Login to "apps" \ here`,
+		},
+		{
+			name:   "escaped whitespace",
+			input:  "\"\\ \\\t\"",
+			output: " \t",
+		},
+		{
+			name:   "obs-text is kept",
+			input:  "\"foo-\xe4\"",
+			output: "foo-\xe4",
+		},
+		{
+			name:   "escaped obs-text is kept",
+			input:  "\"\\\xe4\\\xff\"",
+			output: "\xe4\xff",
+		},
+		{
+			name:   "utf-8 is kept",
+			input:  "\"foo-\xc3\xa4\"",
+			output: "foo-\xc3\xa4",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if output := unquote(testCase.input); output != testCase.output {
+				t.Errorf("expected %q, got %q", testCase.output, output)
+			}
+		})
+	}
+}
+
+func TestParseInvalidQuotedString(t *testing.T) {
+	for _, testCase := range []struct {
+		name  string
+		input string
+		error string
+	}{
+		{
+			name:  "unterminated",
+			input: `Basic realm="simple`,
+			error: "unterminated quoted string at offset 12",
+		},
+		{
+			name:  "unterminated after a backslash",
+			input: `Basic realm="simple\"`,
+			error: "unterminated quoted string at offset 12",
+		},
+		{
+			name:  "trailing backslash",
+			input: `Basic realm="simple\`,
+			error: "unterminated quoted string at offset 12",
+		},
+		{
+			name:  "empty",
+			input: `Basic realm="`,
+			error: "unterminated quoted string at offset 12",
+		},
+		{
+			name:  "text after the closing quote",
+			input: `Basic realm="simple"s`,
+			error: `syntax error at offset 20: unexpected 's'`,
+		},
+		{
+			name:  "control character",
+			input: "Basic realm=\"sim\nple\"",
+			error: `syntax error at offset 16: unexpected '\n'`,
+		},
+		{
+			name:  "escaped control character",
+			input: "Basic realm=\"sim\\\x7fple\"",
+			error: `syntax error at offset 17: unexpected '\x7f'`,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			challenges, err := ParseString(testCase.input)
+			if !errors.Is(err, ErrInvalidChallenge) || !strings.Contains(err.Error(), testCase.error) {
+				t.Errorf("expected an invalid challenge error containing %q, got %v", testCase.error, err)
+			}
+			if challenges != nil {
+				t.Errorf("expected no challenges, got %v", challenges)
+			}
+		})
+	}
+}
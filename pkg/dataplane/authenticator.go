@@ -6,12 +6,13 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 
-	"github.com/Azure/msi-dataplane/pkg/dataplane/internal/challenge"
+	"github.com/Azure/msi-dataplane/pkg/challenge"
 )
 
 var (
@@ -19,12 +20,24 @@ var (
 )
 
 // Authenticating with MSI: https://eng.ms/docs/products/arm/rbac/managed_identities/msionboardinginteractionwithmsi .
-func newAuthenticatorPolicy(cred azcore.TokenCredential, audience string) policy.Policy {
+// The tenant for the identity is learned from the first challenge and remembered in tenants under the identity's key,
+// so that later requests for the same identity can be authorized up-front, saving the round-trip for the challenge.
+// One host serves identities from many tenants, so tenants are not shared between identities. When
+// trustedAuthorityHosts is set, challenges for authorities on other hosts are rejected.
+func newAuthenticatorPolicy(cred azcore.TokenCredential, audience string, tenants *tenantCache, identity string, trustedAuthorityHosts []string) policy.Policy {
+	scopes := []string{audience + "/.default"}
 	return runtime.NewBearerTokenPolicy(cred, nil, &policy.BearerTokenOptions{
 		AuthorizationHandler: policy.AuthorizationHandler{
-			// Make an unauthenticated request
-			OnRequest: func(*policy.Request, func(policy.TokenRequestOptions) error) error {
-				return nil
+			// Authorize up-front if we know the tenant for this identity, otherwise make an unauthenticated request
+			OnRequest: func(req *policy.Request, authenticateAndAuthorize func(policy.TokenRequestOptions) error) error {
+				tenantID, known := tenants.get(identity)
+				if !known {
+					return nil
+				}
+				return authenticateAndAuthorize(policy.TokenRequestOptions{
+					Scopes:   scopes,
+					TenantID: tenantID,
+				})
 			},
 			// Inspect WWW-Authenticate header returned from challenge
 			OnChallenge: func(req *policy.Request, resp *http.Response, authenticateAndAuthorize func(policy.TokenRequestOptions) error) error {
@@ -38,16 +51,18 @@ func newAuthenticatorPolicy(cred azcore.TokenCredential, audience string) policy
 				if err != nil {
 					return fmt.Errorf("%w: %w", errInvalidAuthHeader, err)
 				}
-				tenantID := strings.ToLower(strings.Trim(u.Path, "/"))
-
-				req.Raw().Context()
+				tenantID, err := tenantFromAuthority(u, trustedAuthorityHosts)
+				if err != nil {
+					return err
+				}
+				tenants.set(identity, tenantID)
 
 				// Note: "In api versions prior to 2023-09-30, the audience is included in the bearer challenge, but we recommend that partners
 				// rely on hard-configuring the explicit values above for security reasons."
 
 				// Authenticate from tenantID and audience
 				return authenticateAndAuthorize(policy.TokenRequestOptions{
-					Scopes:   []string{audience + "/.default"},
+					Scopes:   scopes,
 					TenantID: tenantID,
 				})
 			},
@@ -55,24 +70,51 @@ func newAuthenticatorPolicy(cred azcore.TokenCredential, audience string) policy
 	})
 }
 
+// tenantCache remembers the tenant that each identity was challenged for, keyed by identityKey.
+type tenantCache struct {
+	lock    sync.RWMutex
+	tenants map[string]string
+}
+
+func newTenantCache() *tenantCache {
+	return &tenantCache{tenants: map[string]string{}}
+}
+
+// identityKey identifies the identity behind an x-ms-identity-url by its host and path.
+func identityKey(identityURL *url.URL) string {
+	return strings.ToLower(identityURL.Host) + identityURL.EscapedPath()
+}
+
+func (c *tenantCache) get(identity string) (string, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	tenantID, known := c.tenants[identity]
+	return tenantID, known
+}
+
+func (c *tenantCache) set(identity, tenantID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tenants[identity] = tenantID
+}
+
 func parseChallengeHeader(headers http.Header) (string, error) {
+	// errors in challenges for other schemes do not matter, as long as the bearer challenge is valid
 	challenges, err := challenge.Parse(headers)
-	if err != nil {
+	if err != nil && challenges == nil {
 		return "", fmt.Errorf("%w: %w", errInvalidAuthHeader, err)
 	}
 	if len(challenges) == 0 {
 		return "", fmt.Errorf("%w: %s", errInvalidAuthHeader, "no challenges found")
 	}
-	var bearer *challenge.Challenge
-	for _, c := range challenges {
-		if c.Scheme == "Bearer" {
-			bearer = &c
-		}
-	}
-	if bearer == nil {
+	bearer, found := challenges.ForScheme("Bearer")
+	if !found {
 		return "", fmt.Errorf("%w: %s", errInvalidAuthHeader, "no bearer challenge found")
 	}
-	authParam, provided := bearer.Parameters["authorization"]
+	if bearer.Err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidAuthHeader, bearer.Err)
+	}
+	authParam, provided := bearer.Param("authorization")
 	if !provided {
 		return "", fmt.Errorf("%w: %s", errInvalidAuthHeader, "no authorization parameter in bearer challenge")
 	}
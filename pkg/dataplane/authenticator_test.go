@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	. "github.com/onsi/gomega"
+
+	"github.com/Azure/msi-dataplane/pkg/challenge"
 )
 
 type fakeTransport struct {
@@ -57,6 +60,82 @@ func TestNewAuthenticatorPolicy(t *testing.T) {
 				g.Expect(resp).To(Equal(fakeTransport.resps[1]))
 			},
 		},
+		{
+			name: "Returns success when the scheme and parameter differ in case",
+			fakeTransport: &fakeTransport{
+				resps: []*http.Response{
+					{
+						StatusCode: http.StatusUnauthorized,
+						Header: http.Header{
+							"Www-Authenticate": []string{
+								`bearer Authorization="https://login.windows-ppe.net/5D929AE3-B37C-46AA-A3C8-C1558902F101"`,
+							},
+						},
+						Body: http.NoBody,
+					},
+					{
+						Body: http.NoBody,
+					},
+				},
+			},
+			validateRes: func(g *WithT, fakeTransport *fakeTransport, resp *http.Response, err error) {
+				g.Expect(fakeTransport.reqs[1].Header.Get("authorization")).To(Equal(
+					"Bearer fake_token, tenantID 5d929ae3-b37c-46aa-a3c8-c1558902f101, " +
+						"scopes [https://identity_url.com//.default]"))
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp).To(Equal(fakeTransport.resps[1]))
+			},
+		},
+		{
+			name: "Returns success when another challenge has duplicate parameters",
+			fakeTransport: &fakeTransport{
+				resps: []*http.Response{
+					{
+						StatusCode: http.StatusUnauthorized,
+						Header: http.Header{
+							"Www-Authenticate": []string{
+								`Basic realm="one", realm="two", ` +
+									`Bearer authorization="https://login.windows-ppe.net/5D929AE3-B37C-46AA-A3C8-C1558902F101"`,
+							},
+						},
+						Body: http.NoBody,
+					},
+					{
+						Body: http.NoBody,
+					},
+				},
+			},
+			validateRes: func(g *WithT, fakeTransport *fakeTransport, resp *http.Response, err error) {
+				g.Expect(fakeTransport.reqs[1].Header.Get("authorization")).To(Equal(
+					"Bearer fake_token, tenantID 5d929ae3-b37c-46aa-a3c8-c1558902f101, " +
+						"scopes [https://identity_url.com//.default]"))
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(resp).To(Equal(fakeTransport.resps[1]))
+			},
+		},
+		{
+			name: "failure, duplicate authorization parameters",
+			fakeTransport: &fakeTransport{
+				resps: []*http.Response{
+					{
+						StatusCode: http.StatusUnauthorized,
+						Header: http.Header{
+							"Www-Authenticate": []string{
+								`Bearer authorization="https://login.windows-ppe.net/5D929AE3-B37C-46AA-A3C8-C1558902F101", ` +
+									`Authorization="https://login.example.com/8a1290f5-b9fc-4f74-87ac-9d5e98051efd"`,
+							},
+						},
+						Body: http.NoBody,
+					},
+				},
+			},
+			validateRes: func(g *WithT, fakeTransport *fakeTransport, resp *http.Response, err error) {
+				g.Expect(fakeTransport.reqs[0].Header).NotTo(HaveKey("Authorization"))
+				g.Expect(err).To(MatchError(errInvalidAuthHeader))
+				g.Expect(err).To(MatchError(challenge.ErrDuplicateParameter))
+				g.Expect(resp).To(Equal(fakeTransport.resps[0]))
+			},
+		},
 		{
 			name: "failure, authorization is not URL",
 			fakeTransport: &fakeTransport{
@@ -84,7 +163,7 @@ func TestNewAuthenticatorPolicy(t *testing.T) {
 
 			pipeline := runtime.NewPipeline("", "", runtime.PipelineOptions{
 				PerCall: []policy.Policy{
-					newAuthenticatorPolicy(&FakeCredential{}, "https://identity_url.com/"),
+					newAuthenticatorPolicy(&FakeCredential{}, "https://identity_url.com/", newTenantCache(), "localhost/identity", nil),
 				},
 			}, &policy.ClientOptions{
 				Transport: tt.fakeTransport,
@@ -106,3 +185,84 @@ func (f *FakeCredential) GetToken(ctx context.Context, opts policy.TokenRequestO
 		Token: fmt.Sprintf("fake_token, tenantID %s, scopes %v", opts.TenantID, opts.Scopes),
 	}, nil
 }
+
+func TestAuthenticatorPolicyRemembersTenant(t *testing.T) {
+	g := NewWithT(t)
+
+	challenge := func(tenantID string) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header: http.Header{
+				"Www-Authenticate": []string{`Bearer authorization="https://login.windows-ppe.net/` + tenantID + `"`},
+			},
+			Body: http.NoBody,
+		}
+	}
+	ok := func() *http.Response { return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody} }
+
+	tenants := newTenantCache()
+	do := func(identityURL string, transport *fakeTransport) (*http.Response, error) {
+		// every client gets its own pipeline, but they share the tenants learned by the factory
+		parsed, err := url.Parse(identityURL)
+		g.Expect(err).NotTo(HaveOccurred())
+		pipeline := runtime.NewPipeline("", "", runtime.PipelineOptions{
+			PerCall: []policy.Policy{
+				newAuthenticatorPolicy(&FakeCredential{}, "https://identity_url.com/", tenants, identityKey(parsed), nil),
+			},
+		}, &policy.ClientOptions{
+			Transport: transport,
+		})
+		req, err := runtime.NewRequest(context.Background(), http.MethodGet, identityURL)
+		g.Expect(err).NotTo(HaveOccurred())
+		return pipeline.Do(req)
+	}
+	const (
+		first  = "https://localhost/subscriptions/first/credentials/v2/identities"
+		second = "https://localhost/subscriptions/second/credentials/v2/identities"
+	)
+
+	t.Log("the first request for an identity learns the tenant from the challenge")
+	learn := &fakeTransport{resps: []*http.Response{challenge("5D929AE3-B37C-46AA-A3C8-C1558902F101"), ok()}}
+	_, err := do(first, learn)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(learn.reqs).To(HaveLen(2))
+	g.Expect(learn.reqs[0].Header).NotTo(HaveKey("Authorization"))
+
+	t.Log("later requests for the identity are authorized up-front")
+	remembered := &fakeTransport{resps: []*http.Response{ok()}}
+	_, err = do(first, remembered)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(remembered.reqs).To(HaveLen(1))
+	g.Expect(remembered.reqs[0].Header.Get("Authorization")).To(Equal(
+		"Bearer fake_token, tenantID 5d929ae3-b37c-46aa-a3c8-c1558902f101, " +
+			"scopes [https://identity_url.com//.default]"))
+
+	t.Log("a rejected token falls back to the challenge")
+	rejected := &fakeTransport{resps: []*http.Response{challenge("0C6F4A37-6F3F-4D3A-8C2A-2C4B5D4E6F70"), ok()}}
+	_, err = do(first, rejected)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rejected.reqs).To(HaveLen(2))
+	g.Expect(rejected.reqs[1].Header.Get("Authorization")).To(ContainSubstring("tenantID 0c6f4a37-6f3f-4d3a-8c2a-2c4b5d4e6f70"))
+	tenantID, known := tenants.get("localhost/subscriptions/first/credentials/v2/identities")
+	g.Expect(known).To(BeTrue())
+	g.Expect(tenantID).To(Equal("0c6f4a37-6f3f-4d3a-8c2a-2c4b5d4e6f70"))
+
+	t.Log("an identity in another tenant on the same host learns its own tenant")
+	other := &fakeTransport{resps: []*http.Response{challenge("6A1D0B3E-2F47-4C8B-9E5D-7F3A2B1C0D9E"), ok()}}
+	_, err = do(second, other)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(other.reqs).To(HaveLen(2))
+	g.Expect(other.reqs[0].Header).NotTo(HaveKey("Authorization"))
+
+	t.Log("identities on the same host keep their own tenants")
+	for identityURL, tenantID := range map[string]string{
+		first:  "0c6f4a37-6f3f-4d3a-8c2a-2c4b5d4e6f70",
+		second: "6a1d0b3e-2f47-4c8b-9e5d-7f3a2b1c0d9e",
+	} {
+		transport := &fakeTransport{resps: []*http.Response{ok()}}
+		_, err = do(identityURL, transport)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(transport.reqs).To(HaveLen(1))
+		g.Expect(transport.reqs[0].Header.Get("Authorization")).To(ContainSubstring("tenantID " + tenantID))
+	}
+}
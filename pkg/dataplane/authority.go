@@ -0,0 +1,74 @@
+package dataplane
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Entra authority hosts for each cloud.
+var (
+	azurePublicAuthorityHosts     = []string{"login.microsoftonline.com", "login.microsoft.com", "login.windows.net", "sts.windows.net"}
+	azureGovernmentAuthorityHosts = []string{"login.microsoftonline.us", "login.usgovcloudapi.net"}
+	azureChinaAuthorityHosts      = []string{"login.chinacloudapi.cn", "login.partner.microsoftonline.cn"}
+	azurePPEAuthorityHosts        = []string{"login.windows-ppe.net"}
+)
+
+// AzurePublicAuthorityHosts returns the Entra authority hosts for Azure public cloud, for use with WithTrustedAuthorityHosts.
+func AzurePublicAuthorityHosts() []string { return slices.Clone(azurePublicAuthorityHosts) }
+
+// AzureGovernmentAuthorityHosts returns the Entra authority hosts for Azure Government, for use with WithTrustedAuthorityHosts.
+func AzureGovernmentAuthorityHosts() []string { return slices.Clone(azureGovernmentAuthorityHosts) }
+
+// AzureChinaAuthorityHosts returns the Entra authority hosts for Azure China, for use with WithTrustedAuthorityHosts.
+func AzureChinaAuthorityHosts() []string { return slices.Clone(azureChinaAuthorityHosts) }
+
+// AzurePPEAuthorityHosts returns the Entra authority hosts for the PPE environment, for use with WithTrustedAuthorityHosts.
+func AzurePPEAuthorityHosts() []string { return slices.Clone(azurePPEAuthorityHosts) }
+
+// ErrInvalidTenantID is returned when the tenant in an authentication challenge is not a GUID.
+var ErrInvalidTenantID = errors.New("tenant ID is not a GUID")
+
+var tenantIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// UntrustedAuthorityError is returned when the MSI data plane challenges a client to authenticate
+// with an authority that is not on the allowlist configured with WithTrustedAuthorityHosts.
+type UntrustedAuthorityError struct {
+	// Authority is the authority URL from the challenge.
+	Authority string
+}
+
+func (e *UntrustedAuthorityError) Error() string {
+	return fmt.Sprintf("challenge requested authentication with untrusted authority %q", e.Authority)
+}
+
+// WithTrustedAuthorityHosts limits the Entra authority hosts that clients will authenticate with when
+// challenged by the MSI data plane, for instance to AzurePublicAuthorityHosts(). Hosts must match exactly,
+// so subdomains of a trusted host are not trusted unless they are listed as well. Challenges from other
+// hosts fail with an *UntrustedAuthorityError, while challenges from a trusted host for a tenant that is not
+// a GUID fail with ErrInvalidTenantID. By default, any authority host is trusted and the tenant is taken from
+// the authority as it is.
+func WithTrustedAuthorityHosts(hosts ...string) ClientFactoryOption {
+	return func(c *clientOpts) {
+		c.trustedAuthorityHosts = append(c.trustedAuthorityHosts, hosts...)
+	}
+}
+
+// tenantFromAuthority validates the authority from a challenge and determines the tenant it identifies.
+// When trustedHosts is empty, authorities on any host are accepted and the tenant is not validated.
+func tenantFromAuthority(authority *url.URL, trustedHosts []string) (string, error) {
+	tenantID := strings.ToLower(strings.Trim(authority.Path, "/"))
+	if len(trustedHosts) == 0 {
+		return tenantID, nil
+	}
+	if !strings.EqualFold(authority.Scheme, "https") || !isTrustedAuthorityHost(authority.Hostname(), trustedHosts) {
+		return "", &UntrustedAuthorityError{Authority: authority.String()}
+	}
+	if !tenantIDPattern.MatchString(tenantID) {
+		return "", fmt.Errorf("%w: %w: %q", errInvalidAuthHeader, ErrInvalidTenantID, tenantID)
+	}
+	return tenantID, nil
+}
@@ -0,0 +1,142 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+func TestTenantFromAuthority(t *testing.T) {
+	for _, testCase := range []struct {
+		name           string
+		authority      string
+		trustedHosts   []string
+		expectedTenant string
+		expectedErr    func(error) bool
+	}{
+		{
+			name:           "any host is trusted without an allowlist",
+			authority:      "https://127.0.0.1:8443/8A1290F5-B9FC-4F74-87AC-9D5E98051EFD",
+			expectedTenant: "8a1290f5-b9fc-4f74-87ac-9d5e98051efd",
+		},
+		{
+			name:           "trusted host",
+			authority:      "https://LOGIN.microsoftonline.com/8a1290f5-b9fc-4f74-87ac-9d5e98051efd/",
+			trustedHosts:   AzurePublicAuthorityHosts(),
+			expectedTenant: "8a1290f5-b9fc-4f74-87ac-9d5e98051efd",
+		},
+		{
+			name:         "host from another cloud",
+			authority:    "https://login.microsoftonline.us/8a1290f5-b9fc-4f74-87ac-9d5e98051efd",
+			trustedHosts: AzurePublicAuthorityHosts(),
+			expectedErr:  isUntrustedAuthority,
+		},
+		{
+			name:         "lookalike host",
+			authority:    "https://login.microsoftonline.com.example.com/8a1290f5-b9fc-4f74-87ac-9d5e98051efd",
+			trustedHosts: AzurePublicAuthorityHosts(),
+			expectedErr:  isUntrustedAuthority,
+		},
+		{
+			name:         "insecure scheme",
+			authority:    "http://login.microsoftonline.com/8a1290f5-b9fc-4f74-87ac-9d5e98051efd",
+			trustedHosts: AzurePublicAuthorityHosts(),
+			expectedErr:  isUntrustedAuthority,
+		},
+		{
+			name:         "tenant is not a GUID",
+			authority:    "https://login.microsoftonline.com/common",
+			trustedHosts: AzurePublicAuthorityHosts(),
+			expectedErr:  isInvalidTenant,
+		},
+		{
+			name:         "tenant has extra path segments",
+			authority:    "https://login.microsoftonline.com/8a1290f5-b9fc-4f74-87ac-9d5e98051efd/oauth2",
+			trustedHosts: AzurePublicAuthorityHosts(),
+			expectedErr:  isInvalidTenant,
+		},
+		{
+			name:           "tenant is not validated without an allowlist",
+			authority:      "https://login.windows-ppe.net/Common/",
+			expectedTenant: "common",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			authority, err := url.Parse(testCase.authority)
+			if err != nil {
+				t.Fatalf("failed to parse authority: %v", err)
+			}
+			opts := &clientOpts{}
+			WithTrustedAuthorityHosts(testCase.trustedHosts...)(opts)
+
+			tenantID, err := tenantFromAuthority(authority, opts.trustedAuthorityHosts)
+			if testCase.expectedErr != nil {
+				if !testCase.expectedErr(err) {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tenantID != testCase.expectedTenant {
+				t.Errorf("expected tenant %q, got %q", testCase.expectedTenant, tenantID)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorPolicyRejectsUntrustedAuthority(t *testing.T) {
+	transport := &fakeTransport{resps: []*http.Response{{
+		StatusCode: http.StatusUnauthorized,
+		Header: http.Header{
+			"Www-Authenticate": []string{`Bearer authorization="https://login.example.com/8a1290f5-b9fc-4f74-87ac-9d5e98051efd"`},
+		},
+		Body: http.NoBody,
+	}}}
+	tenants := newTenantCache()
+	pipeline := runtime.NewPipeline("", "", runtime.PipelineOptions{
+		PerCall: []policy.Policy{
+			newAuthenticatorPolicy(&FakeCredential{}, "https://identity_url.com/", tenants, "localhost/identity", AzurePublicAuthorityHosts()),
+		},
+	}, &policy.ClientOptions{
+		Transport: transport,
+	})
+	req, err := runtime.NewRequest(context.Background(), http.MethodGet, "https://localhost/")
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	if _, err := pipeline.Do(req); !isUntrustedAuthority(err) {
+		t.Errorf("expected an untrusted authority error, got %v", err)
+	}
+	if len(transport.reqs) != 1 {
+		t.Errorf("expected no request to be made after the challenge, got %d requests", len(transport.reqs))
+	}
+	if _, known := tenants.get("localhost/identity"); known {
+		t.Errorf("expected the tenant from an untrusted authority not to be remembered")
+	}
+}
+
+func TestAuthorityHostsAreCopies(t *testing.T) {
+	hosts := AzurePublicAuthorityHosts()
+	hosts[0] = "login.example.com"
+	if got := AzurePublicAuthorityHosts()[0]; got != "login.microsoftonline.com" {
+		t.Errorf("expected changes to the returned hosts not to leak, got %q", got)
+	}
+}
+
+// isInvalidTenant checks for a tenant error, which callers must not mistake for an untrusted authority.
+func isInvalidTenant(err error) bool {
+	return errors.Is(err, ErrInvalidTenantID) && errors.Is(err, errInvalidAuthHeader) && !isUntrustedAuthority(err)
+}
+
+func isUntrustedAuthority(err error) bool {
+	var untrusted *UntrustedAuthorityError
+	return errors.As(err, &untrusted)
+}
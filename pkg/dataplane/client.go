@@ -11,7 +11,8 @@ import (
 // - the generated clients incorrectly expose all sorts of internal logic like the request body, etc, when we just want a clean client
 // Ideally we wouldn't need this wrapper, but it's much easier to implement this here than update the generator.
 
-// Client exposes the API for the MSI data plane.
+// Client exposes the API for the MSI data plane. When the service responds with an error, the
+// error returned is an *MSIError, which can be classified with errors.Is and sentinels like ErrIdentityNotFound.
 type Client interface {
 	// DeleteSystemAssignedIdentity deletes the system-assigned identity for a proxy resource.
 	DeleteSystemAssignedIdentity(ctx context.Context) error
@@ -22,7 +23,8 @@ type Client interface {
 	// GetUserAssignedIdentitiesCredentials retrieves the credentials for any user-assigned identities associated with the proxy resource.
 	GetUserAssignedIdentitiesCredentials(ctx context.Context, request UserAssignedIdentitiesRequest) (*ManagedIdentityCredentials, error)
 
-	// MoveIdentity moves the identity from one resource group into another.
+	// MoveIdentity moves the identity from one resource group into another. As moving is not idempotent,
+	// failed requests are not retried unless RetryOptions.RetryMoveIdentity is set on the factory.
 	MoveIdentity(ctx context.Context, request MoveIdentityRequest) (*MoveIdentityResponse, error)
 }
 type clientAdapter struct {
@@ -34,20 +36,20 @@ var _ Client = (*clientAdapter)(nil)
 
 func (c *clientAdapter) DeleteSystemAssignedIdentity(ctx context.Context) error {
 	_, err := c.delegate.Deleteidentity(ctx, c.hostPath, nil)
-	return err
+	return asMSIError(err)
 }
 
 func (c *clientAdapter) GetSystemAssignedIdentityCredentials(ctx context.Context) (*ManagedIdentityCredentials, error) {
 	resp, err := c.delegate.Getcred(ctx, c.hostPath, nil)
-	return &resp.ManagedIdentityCredentials, err
+	return &resp.ManagedIdentityCredentials, asMSIError(err)
 }
 
 func (c *clientAdapter) GetUserAssignedIdentitiesCredentials(ctx context.Context, request UserAssignedIdentitiesRequest) (*ManagedIdentityCredentials, error) {
 	resp, err := c.delegate.Getcreds(ctx, c.hostPath, request, nil)
-	return &resp.ManagedIdentityCredentials, err
+	return &resp.ManagedIdentityCredentials, asMSIError(err)
 }
 
 func (c *clientAdapter) MoveIdentity(ctx context.Context, request MoveIdentityRequest) (*MoveIdentityResponse, error) {
-	resp, err := c.delegate.Moveidentity(ctx, c.hostPath, request, nil)
-	return &resp.MoveIdentityResponse, err
+	resp, err := c.delegate.Moveidentity(withNonIdempotent(ctx), c.hostPath, request, nil)
+	return &resp.MoveIdentityResponse, asMSIError(err)
 }
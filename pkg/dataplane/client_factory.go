@@ -12,6 +12,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/go-logr/logr"
 
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
 	"github.com/Azure/msi-dataplane/pkg/dataplane/internal/client"
 )
 
@@ -31,7 +32,10 @@ type ClientFactory interface {
 }
 
 type clientOpts struct {
-	logger *logr.Logger
+	logger                *logr.Logger
+	retryOptions          RetryOptions
+	trustedAuthorityHosts []string
+	clock                 clock.Clock
 }
 
 type ClientFactoryOption func(*clientOpts)
@@ -44,6 +48,13 @@ func WithClientLogger(logger *logr.Logger) ClientFactoryOption {
 	}
 }
 
+// WithClientClock sets the clock used to time retries, for instance to a clock.Fake in tests.
+func WithClientClock(clock clock.Clock) ClientFactoryOption {
+	return func(c *clientOpts) {
+		c.clock = clock
+	}
+}
+
 // NewClientFactory creates a new MSI data plane client factory. The credentials and audience presented
 // are for the first-party credential. As the server to be contacted for each identity varies, a factory
 // is returned that can create clients on-demand.
@@ -51,15 +62,27 @@ func NewClientFactory(cred azcore.TokenCredential, audience string, opts *azcore
 	defaultLogger := logr.FromSlogHandler(slog.NewTextHandler(os.Stdout, nil))
 	cfOpts := &clientOpts{
 		logger: &defaultLogger,
+		clock:  clock.Real,
 	}
 	for _, opt := range clientFactoryOpts {
 		opt(cfOpts)
 	}
+	cfOpts.retryOptions = cfOpts.retryOptions.withDefaults()
+
+	// retries are handled by our own policy, so the default retry policy from azcore must not run as well
+	var pipelineOpts azcore.ClientOptions
+	if opts != nil {
+		pipelineOpts = *opts
+	}
+	pipelineOpts.Retry = policy.RetryOptions{MaxRetries: -1}
+
 	return &clientFactory{
-		cred:       cred,
-		audience:   audience,
-		cfOpts:     cfOpts,
-		clientOpts: opts,
+		cred:        cred,
+		audience:    audience,
+		cfOpts:      cfOpts,
+		clientOpts:  &pipelineOpts,
+		retryBudget: newRetryBudget(cfOpts.retryOptions.Budget),
+		tenants:     newTenantCache(),
 	}
 }
 
@@ -68,6 +91,10 @@ type clientFactory struct {
 	audience   string
 	cfOpts     *clientOpts
 	clientOpts *azcore.ClientOptions
+	// retryBudget is shared by all clients from the factory
+	retryBudget *retryBudget
+	// tenants remembers the tenant for each identity, so new clients can skip the authentication challenge
+	tenants *tenantCache
 }
 
 var _ ClientFactory = (*clientFactory)(nil)
@@ -102,7 +129,8 @@ func (c *clientFactory) NewClient(identityURL string) (Client, error) {
 				req.Raw().URL.RawQuery = query.Encode()
 				return req.Next()
 			}),
-			newAuthenticatorPolicy(c.cred, c.audience),
+			newRetryPolicy(c.cfOpts.retryOptions, c.retryBudget, c.cfOpts.logger, c.cfOpts.clock),
+			newAuthenticatorPolicy(c.cred, c.audience, c.tenants, identityKey(parsedURL), c.cfOpts.trustedAuthorityHosts),
 		},
 	}, c.clientOpts)
 	if err != nil {
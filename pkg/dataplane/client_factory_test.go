@@ -318,6 +318,9 @@ func TestClient(t *testing.T) {
 			if err == nil {
 				t.Error("expected an error getting user assigned identity credentials, got none")
 			}
+			if !errors.Is(err, ErrNotFound) || !errors.Is(err, ErrIdentityNotFound) {
+				t.Errorf("expected a not found error, got: %v", err)
+			}
 			expected := &azcore.ResponseError{}
 			if !errors.As(err, &expected) {
 				t.Errorf("expected error %T, got: %T", expected, err)
@@ -0,0 +1,196 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time only moves when it is told to, firing the timers and tickers that come due.
+// Like their counterparts in the time package, timers and tickers hold at most one undelivered event.
+type Fake struct {
+	lock    sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+var _ Clock = (*Fake)(nil)
+
+// NewFake creates a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.changed = sync.NewCond(&f.lock)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) Until(t time.Time) time.Duration {
+	return t.Sub(f.Now())
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return &fakeTimer{waiter: f.newWaiter(d, 0)}
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clock.Fake.NewTicker")
+	}
+	return &fakeTicker{waiter: f.newWaiter(d, d)}
+}
+
+// Step moves the time forward, firing the timers and tickers that come due.
+func (f *Fake) Step(d time.Duration) {
+	f.SetTime(f.Now().Add(d))
+}
+
+// SetTime moves the time to now, firing the timers and tickers that come due.
+func (f *Fake) SetTime(now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.now = now
+	pending := f.waiters[:0]
+	for _, waiter := range f.waiters {
+		if waiter.deadline.After(now) {
+			pending = append(pending, waiter)
+			continue
+		}
+		select {
+		case waiter.c <- now:
+		default:
+		}
+		if waiter.period > 0 {
+			// like a real ticker, drop the ticks that a slow receiver missed
+			for !waiter.deadline.After(now) {
+				waiter.deadline = waiter.deadline.Add(waiter.period)
+			}
+			pending = append(pending, waiter)
+		} else {
+			waiter.active = false
+		}
+	}
+	f.waiters = pending
+	f.changed.Broadcast()
+}
+
+// Waiters returns the number of timers and tickers that have yet to fire or be stopped.
+func (f *Fake) Waiters() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil waits until at least n timers and tickers are waiting to fire, so that a test can step the
+// clock once the code under test is waiting for it.
+func (f *Fake) BlockUntil(n int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for len(f.waiters) < n {
+		f.changed.Wait()
+	}
+}
+
+type fakeWaiter struct {
+	fake     *Fake
+	c        chan time.Time
+	deadline time.Time
+	period   time.Duration
+	active   bool
+}
+
+func (f *Fake) newWaiter(d, period time.Duration) *fakeWaiter {
+	waiter := &fakeWaiter{fake: f, c: make(chan time.Time, 1), period: period}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	waiter.schedule(d)
+	return waiter
+}
+
+// schedule arms the waiter to fire after d, and must be called with the lock of the clock held.
+func (w *fakeWaiter) schedule(d time.Duration) bool {
+	wasActive := w.active
+	w.deadline = w.fake.now.Add(d)
+	if !w.active {
+		w.active = true
+		w.fake.waiters = append(w.fake.waiters, w)
+	}
+	w.fake.changed.Broadcast()
+	if d <= 0 && w.period == 0 {
+		// fire right away, as a real timer would
+		select {
+		case w.c <- w.fake.now:
+		default:
+		}
+		w.unschedule()
+	}
+	return wasActive
+}
+
+// unschedule disarms the waiter, and must be called with the lock of the clock held.
+func (w *fakeWaiter) unschedule() bool {
+	if !w.active {
+		return false
+	}
+	w.active = false
+	for i, waiter := range w.fake.waiters {
+		if waiter == w {
+			w.fake.waiters = append(w.fake.waiters[:i], w.fake.waiters[i+1:]...)
+			break
+		}
+	}
+	w.fake.changed.Broadcast()
+	return true
+}
+
+type fakeTimer struct {
+	waiter *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.waiter.fake.lock.Lock()
+	defer t.waiter.fake.lock.Unlock()
+	return t.waiter.unschedule()
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.waiter.fake.lock.Lock()
+	defer t.waiter.fake.lock.Unlock()
+	return t.waiter.schedule(d)
+}
+
+type fakeTicker struct {
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.waiter.fake.lock.Lock()
+	defer t.waiter.fake.lock.Unlock()
+	t.waiter.unschedule()
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for clock.Fake ticker Reset")
+	}
+	t.waiter.fake.lock.Lock()
+	defer t.waiter.fake.lock.Unlock()
+	t.waiter.period = d
+	t.waiter.schedule(d)
+}
@@ -0,0 +1,83 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFakeTimer(t *testing.T) {
+	fake := NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	timer := fake.NewTimer(time.Hour)
+	if fake.Waiters() != 1 {
+		t.Errorf("expected the timer to be waiting")
+	}
+
+	fake.Step(59 * time.Minute)
+	if fired(timer.C()) {
+		t.Errorf("expected the timer not to fire early")
+	}
+	fake.Step(time.Minute)
+	if !fired(timer.C()) {
+		t.Errorf("expected the timer to fire once due")
+	}
+	if fake.Waiters() != 0 {
+		t.Errorf("expected the timer to stop waiting once fired")
+	}
+
+	if timer.Reset(time.Hour) {
+		t.Errorf("expected the fired timer to be inactive")
+	}
+	if !timer.Stop() {
+		t.Errorf("expected the reset timer to be active")
+	}
+	fake.Step(2 * time.Hour)
+	if fired(timer.C()) {
+		t.Errorf("expected the stopped timer not to fire")
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	fake := NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	ticker := fake.NewTicker(time.Hour)
+
+	fake.Step(3 * time.Hour)
+	if !fired(ticker.C()) {
+		t.Errorf("expected the ticker to tick")
+	}
+	if fired(ticker.C()) {
+		t.Errorf("expected missed ticks to be dropped")
+	}
+	fake.Step(time.Hour)
+	if !fired(ticker.C()) {
+		t.Errorf("expected the ticker to keep ticking")
+	}
+
+	ticker.Stop()
+	fake.Step(time.Hour)
+	if fired(ticker.C()) {
+		t.Errorf("expected the stopped ticker not to tick")
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	fake := NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	done := make(chan time.Time)
+	go func() {
+		done <- <-fake.NewTimer(24 * time.Hour).C()
+	}()
+
+	fake.BlockUntil(1)
+	fake.Step(24 * time.Hour)
+	if got, expected := <-done, fake.Now(); !got.Equal(expected) {
+		t.Errorf("expected the timer to fire at %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,93 @@
+package dataplane
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// CloudConfiguration describes the MSI data plane and the Entra authorities of one Azure cloud.
+type CloudConfiguration struct {
+	// Name identifies the cloud in errors and logs.
+	Name string
+	// Audience is the audience for which the first-party credential requests tokens to call the MSI data plane.
+	Audience string
+	// AuthorityHosts are the Entra hosts trusted to issue tokens in this cloud. Hosts must match exactly.
+	AuthorityHosts []string
+	// AllowRegionalAuthorityHosts trusts subdomains of the AuthorityHosts, like the regional endpoint
+	// westus2.login.microsoft.com, as the authentication endpoint of loaded credentials. It does not
+	// widen the hosts trusted in authentication challenges, which name the global hosts.
+	AllowRegionalAuthorityHosts bool
+}
+
+// Configurations for the Azure clouds. Tokens for the MSI data plane are requested for the Azure Resource Manager
+// audience of the cloud, as published for the public, government and China clouds in the ResourceManager service
+// configuration of github.com/Azure/azure-sdk-for-go/sdk/azcore/arm/runtime. The PPE (dogfood) Resource Manager
+// shares the audience of the public cloud.
+var (
+	AzurePublicCloud = CloudConfiguration{
+		Name:           "AzurePublic",
+		Audience:       "https://management.core.windows.net",
+		AuthorityHosts: AzurePublicAuthorityHosts(),
+	}
+	AzureGovernmentCloud = CloudConfiguration{
+		Name:           "AzureGovernment",
+		Audience:       "https://management.core.usgovcloudapi.net",
+		AuthorityHosts: AzureGovernmentAuthorityHosts(),
+	}
+	AzureChinaCloud = CloudConfiguration{
+		Name:           "AzureChina",
+		Audience:       "https://management.core.chinacloudapi.cn",
+		AuthorityHosts: AzureChinaAuthorityHosts(),
+	}
+	AzurePPECloud = CloudConfiguration{
+		Name:           "AzurePPE",
+		Audience:       "https://management.core.windows.net",
+		AuthorityHosts: AzurePPEAuthorityHosts(),
+	}
+)
+
+// ValidateAuthority ensures that the authority, like the authentication endpoint in a credential
+// payload, is hosted by one of the trusted Entra hosts for the cloud.
+func (c CloudConfiguration) ValidateAuthority(authority string) error {
+	parsed, err := url.Parse(authority)
+	if err != nil {
+		return fmt.Errorf("failed to parse authority %q: %w", authority, err)
+	}
+	if !strings.EqualFold(parsed.Scheme, "https") {
+		return &UntrustedAuthorityError{Authority: authority}
+	}
+	if isTrustedAuthorityHost(parsed.Hostname(), c.AuthorityHosts) {
+		return nil
+	}
+	if c.AllowRegionalAuthorityHosts {
+		if _, parent, found := strings.Cut(parsed.Hostname(), "."); found && isTrustedAuthorityHost(parent, c.AuthorityHosts) {
+			return nil
+		}
+	}
+	return &UntrustedAuthorityError{Authority: authority}
+}
+
+// NewClientFactoryForCloud creates a new MSI data plane client factory for the cloud, using the
+// MSI data plane audience for the cloud and trusting only the Entra authority hosts of the cloud.
+func NewClientFactoryForCloud(cred azcore.TokenCredential, cloud CloudConfiguration, opts *azcore.ClientOptions, clientFactoryOpts ...ClientFactoryOption) ClientFactory {
+	return NewClientFactory(cred, cloud.Audience, opts, append([]ClientFactoryOption{WithTrustedAuthorityHosts(cloud.AuthorityHosts...)}, clientFactoryOpts...)...)
+}
+
+// WithCloud ensures that credentials are only loaded when their authentication endpoint is hosted
+// by one of the trusted Entra hosts for the cloud.
+func WithCloud(cloud CloudConfiguration) Option {
+	return func(c *reloadingCredential) {
+		c.cloud = &cloud
+	}
+}
+
+// isTrustedAuthorityHost determines if the host is one of the trusted hosts.
+func isTrustedAuthorityHost(host string, trustedHosts []string) bool {
+	return slices.ContainsFunc(trustedHosts, func(trusted string) bool {
+		return strings.EqualFold(host, trusted)
+	})
+}
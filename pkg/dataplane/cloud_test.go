@@ -0,0 +1,116 @@
+package dataplane
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/Azure/azure-sdk-for-go/sdk/azcore/arm/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+func TestCloudConfigurationValidateAuthority(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		cloud     CloudConfiguration
+		authority string
+		trusted   bool
+	}{
+		{name: "public", cloud: AzurePublicCloud, authority: "https://login.microsoftonline.com/", trusted: true},
+		{name: "regional endpoint is not trusted by default", cloud: AzurePublicCloud, authority: "https://westus2.login.microsoft.com/"},
+		{name: "regional endpoint when allowed", cloud: regional(AzurePublicCloud), authority: "https://westus2.login.microsoft.com/", trusted: true},
+		{name: "nested subdomain when regional endpoints are allowed", cloud: regional(AzurePublicCloud), authority: "https://a.westus2.login.microsoft.com/"},
+		{name: "lookalike host when regional endpoints are allowed", cloud: regional(AzurePublicCloud), authority: "https://evillogin.microsoftonline.com/"},
+		{name: "government", cloud: AzureGovernmentCloud, authority: "https://login.microsoftonline.us/", trusted: true},
+		{name: "china", cloud: AzureChinaCloud, authority: "https://login.chinacloudapi.cn/", trusted: true},
+		{name: "ppe", cloud: AzurePPECloud, authority: "https://login.windows-ppe.net/", trusted: true},
+		{name: "public authority in government cloud", cloud: AzureGovernmentCloud, authority: "https://login.microsoftonline.com/"},
+		{name: "lookalike host", cloud: AzurePublicCloud, authority: "https://evillogin.microsoftonline.com/"},
+		{name: "insecure scheme", cloud: AzurePublicCloud, authority: "http://login.microsoftonline.com/"},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.cloud.ValidateAuthority(testCase.authority)
+			if testCase.trusted && err != nil {
+				t.Errorf("expected authority to be trusted, got %v", err)
+			}
+			if !testCase.trusted && !isUntrustedAuthority(err) {
+				t.Errorf("expected an untrusted authority error, got %v", err)
+			}
+		})
+	}
+}
+
+func regional(c CloudConfiguration) CloudConfiguration {
+	c.AllowRegionalAuthorityHosts = true
+	return c
+}
+
+func TestCloudConfigurationAudiences(t *testing.T) {
+	for _, testCase := range []struct {
+		cloud    CloudConfiguration
+		azcore   cloud.Configuration
+		audience string
+	}{
+		{cloud: AzurePublicCloud, azcore: cloud.AzurePublic, audience: "https://management.core.windows.net"},
+		{cloud: AzureGovernmentCloud, azcore: cloud.AzureGovernment, audience: "https://management.core.usgovcloudapi.net"},
+		{cloud: AzureChinaCloud, azcore: cloud.AzureChina, audience: "https://management.core.chinacloudapi.cn"},
+		{cloud: AzurePPECloud, azcore: cloud.AzurePublic, audience: "https://management.core.windows.net"},
+	} {
+		t.Run(testCase.cloud.Name, func(t *testing.T) {
+			if diff := cmp.Diff(testCase.audience, testCase.cloud.Audience); diff != "" {
+				t.Errorf("unexpected audience (-want +got):\n%s", diff)
+			}
+			// the audiences are the ones azcore publishes for Resource Manager, without the trailing slash
+			resourceManager := strings.TrimSuffix(testCase.azcore.Services[cloud.ResourceManager].Audience, "/")
+			if diff := cmp.Diff(resourceManager, testCase.cloud.Audience); diff != "" {
+				t.Errorf("audience differs from Resource Manager (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReloadingCredentialWithCloud(t *testing.T) {
+	logger := logr.Discard()
+	credential := &reloadingCredential{lock: &sync.RWMutex{}, logger: &logger, clock: clock.Real, health: &loadHealth{}}
+	WithCloud(AzureGovernmentCloud)(credential)
+
+	publicCredentials := testUserAssignedIdentityCredentials(t, time.Now())
+	var untrusted *UntrustedAuthorityError
+	if err := credential.update(publicCredentials); !errors.As(err, &untrusted) {
+		t.Errorf("expected credentials for another cloud to be rejected, got %v", err)
+	}
+	if credential.current() != nil {
+		t.Errorf("expected no credential to be loaded")
+	}
+
+	governmentCredentials := testUserAssignedIdentityCredentials(t, time.Now())
+	governmentCredentials.AuthenticationEndpoint = ptrTo("https://login.microsoftonline.us/")
+	if err := credential.update(governmentCredentials); err != nil {
+		t.Errorf("expected credentials for the cloud to be loaded, got %v", err)
+	}
+	if credential.current() == nil {
+		t.Errorf("expected a credential to be loaded")
+	}
+
+	withoutEndpoint := testUserAssignedIdentityCredentials(t, time.Now().Add(time.Hour))
+	withoutEndpoint.AuthenticationEndpoint = nil
+	if err := credential.update(withoutEndpoint); !errors.Is(err, errNilField) || !strings.Contains(err.Error(), "not valid for cloud") {
+		t.Errorf("expected credentials without an authentication endpoint to be rejected, got %v", err)
+	}
+}
+
+func TestNewClientFactoryForCloud(t *testing.T) {
+	factory := NewClientFactoryForCloud(&FakeCredential{}, AzureChinaCloud, nil, WithTrustedAuthorityHosts("login.example.com")).(*clientFactory)
+	if factory.audience != AzureChinaCloud.Audience {
+		t.Errorf("expected audience %q, got %q", AzureChinaCloud.Audience, factory.audience)
+	}
+	if diff := cmp.Diff(append(AzureChinaAuthorityHosts(), "login.example.com"), factory.cfOpts.trustedAuthorityHosts); diff != "" {
+		t.Errorf("unexpected trusted hosts (-want +got):\n%s", diff)
+	}
+}
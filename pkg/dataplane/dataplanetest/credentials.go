@@ -0,0 +1,65 @@
+package dataplanetest
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane"
+	"github.com/Azure/msi-dataplane/pkg/dataplane/internal/selfsigned"
+)
+
+// CredentialOptions configure a minted credential. Empty identifiers are filled in with random values
+// and zero times with DefaultCredentialTimes.
+type CredentialOptions struct {
+	TenantID               string
+	ClientID               string
+	ObjectID               string
+	ResourceID             string
+	AuthenticationEndpoint string
+	ClientSecretURL        string
+	Times                  CredentialTimes
+}
+
+// NewUserAssignedIdentityCredentials mints credentials for an identity, backed by a real self-signed
+// certificate whose validity matches the NotBefore and NotAfter times of the credential. The result
+// can be passed to dataplane.GetCredential, formatted for storage or written to disk for a
+// reloading credential to consume.
+func NewUserAssignedIdentityCredentials(opts CredentialOptions) (dataplane.UserAssignedIdentityCredentials, error) {
+	for _, field := range []*string{&opts.TenantID, &opts.ClientID, &opts.ObjectID} {
+		if *field == "" {
+			*field = uuid.NewString()
+		}
+	}
+	if opts.AuthenticationEndpoint == "" {
+		opts.AuthenticationEndpoint = "https://login.microsoftonline.com/"
+	}
+	if opts.Times == (CredentialTimes{}) {
+		opts.Times = DefaultCredentialTimes()
+	}
+
+	clientSecret, err := selfsigned.NewClientSecret(opts.ClientID, opts.Times.NotBefore, opts.Times.NotAfter)
+	if err != nil {
+		return dataplane.UserAssignedIdentityCredentials{}, err
+	}
+
+	credentials := dataplane.UserAssignedIdentityCredentials{
+		AuthenticationEndpoint:     ptrTo(opts.AuthenticationEndpoint),
+		CannotRenewAfter:           ptrTo(opts.Times.CannotRenewAfter.UTC().Format(time.RFC3339)),
+		ClientID:                   ptrTo(opts.ClientID),
+		ClientSecret:               ptrTo(clientSecret),
+		MtlsAuthenticationEndpoint: ptrTo(opts.AuthenticationEndpoint),
+		NotAfter:                   ptrTo(opts.Times.NotAfter.UTC().Format(time.RFC3339)),
+		NotBefore:                  ptrTo(opts.Times.NotBefore.UTC().Format(time.RFC3339)),
+		ObjectID:                   ptrTo(opts.ObjectID),
+		RenewAfter:                 ptrTo(opts.Times.RenewAfter.UTC().Format(time.RFC3339)),
+		TenantID:                   ptrTo(opts.TenantID),
+	}
+	if opts.ResourceID != "" {
+		credentials.ResourceID = ptrTo(opts.ResourceID)
+	}
+	if opts.ClientSecretURL != "" {
+		credentials.ClientSecretURL = ptrTo(opts.ClientSecretURL)
+	}
+	return credentials, nil
+}
@@ -0,0 +1,449 @@
+// Package dataplanetest provides an in-process fake of the MSI data plane for use in tests.
+package dataplanetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/google/uuid"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane"
+)
+
+const (
+	apiVersion = "2024-01-01"
+
+	// identityPathSuffix is appended to the proxy resource ID to form the path of the x-ms-identity-url.
+	identityPathSuffix = "/credentials/v2/identities"
+	// movePathSuffix is appended to the identity path for the move operation.
+	movePathSuffix = "/proxy/move"
+)
+
+// CredentialTimes holds the validity and renewal times stamped on minted credentials.
+type CredentialTimes struct {
+	NotBefore        time.Time
+	NotAfter         time.Time
+	RenewAfter       time.Time
+	CannotRenewAfter time.Time
+}
+
+// DefaultCredentialTimes approximates the lifetime of credentials issued by the MSI data plane:
+// valid for 90 days, renewable after 46 days.
+func DefaultCredentialTimes() CredentialTimes {
+	now := time.Now().UTC().Truncate(time.Second)
+	return CredentialTimes{
+		NotBefore:        now,
+		NotAfter:         now.Add(90 * 24 * time.Hour),
+		RenewAfter:       now.Add(46 * 24 * time.Hour),
+		CannotRenewAfter: now.Add(90 * 24 * time.Hour),
+	}
+}
+
+// Fault describes an error response the Server returns instead of handling an authorized request.
+type Fault struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code and Message populate the ErrorResponse body. When both are empty, no body is sent.
+	Code    string
+	Message string
+	// Header holds additional response headers, for instance Retry-After.
+	Header http.Header
+}
+
+type identity struct {
+	clientID   string
+	objectID   string
+	resourceID string
+}
+
+func newIdentity(resourceID string) *identity {
+	return &identity{
+		clientID:   uuid.NewString(),
+		objectID:   uuid.NewString(),
+		resourceID: resourceID,
+	}
+}
+
+// Server is an in-process fake of the MSI data plane. It implements every operation in the
+// data plane's OpenAPI specification, challenges unauthenticated requests the way the real
+// service does and mints real, self-signed credentials, so that a dataplane.Client and the
+// credentials it returns can be exercised end-to-end without network access.
+type Server struct {
+	server *httptest.Server
+
+	tenantID       string
+	authorityHost  string
+	times          func() CredentialTimes
+	tokenValidator func(token string) bool
+
+	lock           sync.Mutex
+	resourceID     string
+	systemAssigned *identity
+	userAssigned   map[string]*identity
+	faults         []Fault
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithTenantID sets the tenant in which identities live. The tenant is advertised in the
+// authentication challenge and recorded in minted credentials.
+func WithTenantID(tenantID string) Option {
+	return func(s *Server) {
+		s.tenantID = tenantID
+	}
+}
+
+// WithAuthorityHost sets the Entra authority host advertised in the authentication challenge
+// and recorded as the authentication endpoint of minted credentials.
+func WithAuthorityHost(authorityHost string) Option {
+	return func(s *Server) {
+		s.authorityHost = strings.TrimSuffix(authorityHost, "/")
+	}
+}
+
+// WithResourceID sets the ARM ID of the proxy resource whose identities the server manages.
+func WithResourceID(resourceID string) Option {
+	return func(s *Server) {
+		s.resourceID = resourceID
+	}
+}
+
+// WithUserAssignedIdentities registers user-assigned identities, by ARM resource ID, for which
+// the server will issue credentials.
+func WithUserAssignedIdentities(resourceIDs ...string) Option {
+	return func(s *Server) {
+		for _, resourceID := range resourceIDs {
+			s.userAssigned[strings.ToLower(resourceID)] = newIdentity(resourceID)
+		}
+	}
+}
+
+// WithCredentialTimes sets the function used to determine the validity and renewal times of
+// each minted credential.
+func WithCredentialTimes(times func() CredentialTimes) Option {
+	return func(s *Server) {
+		s.times = times
+	}
+}
+
+// WithTokenValidator sets the function used to validate bearer tokens presented to the server.
+// By default, any non-empty bearer token is accepted.
+func WithTokenValidator(validator func(token string) bool) Option {
+	return func(s *Server) {
+		s.tokenValidator = validator
+	}
+}
+
+// NewServer starts a fake MSI data plane. The caller must call Close when finished.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		tenantID:      uuid.NewString(),
+		authorityHost: "https://login.microsoftonline.com",
+		times:         DefaultCredentialTimes,
+		tokenValidator: func(token string) bool {
+			return token != ""
+		},
+		resourceID:   "/subscriptions/" + uuid.NewString() + "/resourcegroups/resource-group/providers/Microsoft.Service/objects/object",
+		userAssigned: map[string]*identity{},
+	}
+	s.systemAssigned = newIdentity("")
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.server = httptest.NewTLSServer(s)
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// ClientOptions returns options for azcore clients that trust the server's TLS certificate.
+func (s *Server) ClientOptions() *azcore.ClientOptions {
+	return &azcore.ClientOptions{
+		Transport: s.server.Client(),
+	}
+}
+
+// TenantID returns the tenant in which identities live.
+func (s *Server) TenantID() string {
+	return s.tenantID
+}
+
+// IdentityURL returns the URL at which credentials for the proxy resource can be managed, in the
+// same shape as the x-ms-identity-url header provided by ARM.
+func (s *Server) IdentityURL() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.identityURL()
+}
+
+func (s *Server) identityURL() string {
+	u, err := url.Parse(s.server.URL)
+	if err != nil {
+		panic(fmt.Sprintf("httptest server URL is invalid: %v", err))
+	}
+	u.Path = s.resourceID + identityPathSuffix
+	u.RawQuery = url.Values{
+		"arpid":  {uuid.NewString()},
+		"keyid":  {uuid.NewString()},
+		"sig":    {"c2lnbmF0dXJl"},
+		"sigver": {"1.0"},
+		"tid":    {s.tenantID},
+	}.Encode()
+	return u.String()
+}
+
+// AddUserAssignedIdentity registers a user-assigned identity, by ARM resource ID, for which the
+// server will issue credentials.
+func (s *Server) AddUserAssignedIdentity(resourceID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.userAssigned[strings.ToLower(resourceID)] = newIdentity(resourceID)
+}
+
+// InjectFaults queues error responses. Each subsequent authorized request consumes one fault,
+// in order, until none remain.
+func (s *Server) InjectFaults(faults ...Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("x-ms-request-id", uuid.NewString())
+
+	if got := r.URL.Query().Get("api-version"); got != apiVersion {
+		writeError(w, http.StatusBadRequest, "InvalidApiVersion", fmt.Sprintf("api-version %q is not supported", got))
+		return
+	}
+
+	token, hasBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !hasBearer || !s.tokenValidator(token) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer authorization="%s/%s"`, s.authorityHost, s.tenantID))
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "a valid bearer token is required")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.faults) > 0 {
+		fault := s.faults[0]
+		s.faults = s.faults[1:]
+		for key, values := range fault.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		if fault.Code == "" && fault.Message == "" {
+			w.WriteHeader(fault.StatusCode)
+			return
+		}
+		writeError(w, fault.StatusCode, fault.Code, fault.Message)
+		return
+	}
+
+	identityPath := s.resourceID + identityPathSuffix
+	switch {
+	case strings.EqualFold(r.URL.Path, identityPath):
+		switch r.Method {
+		case http.MethodGet:
+			s.getCredential(w)
+		case http.MethodPost:
+			s.getCredentials(w, r)
+		case http.MethodDelete:
+			s.deleteIdentity(w)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("method %s is not allowed", r.Method))
+		}
+	case strings.EqualFold(r.URL.Path, identityPath+movePathSuffix):
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("method %s is not allowed", r.Method))
+			return
+		}
+		s.moveIdentity(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("no identity at %s", r.URL.Path))
+	}
+}
+
+func (s *Server) getCredential(w http.ResponseWriter) {
+	if s.systemAssigned == nil {
+		writeError(w, http.StatusNotFound, "NotFound", "the system-assigned identity has been deleted")
+		return
+	}
+	credentials, err := s.systemAssignedCredentials()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	writeJSON(w, credentials)
+}
+
+func (s *Server) getCredentials(w http.ResponseWriter, r *http.Request) {
+	var request dataplane.UserAssignedIdentitiesRequest
+	if err := decodeBody(r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+		return
+	}
+
+	var credentials dataplane.ManagedIdentityCredentials
+	if s.systemAssigned != nil {
+		var err error
+		credentials, err = s.systemAssignedCredentials()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+	}
+
+	for _, resourceID := range request.IdentityIDs {
+		userAssigned, registered := s.userAssigned[strings.ToLower(resourceID)]
+		if !registered {
+			writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("user-assigned identity %s not found", resourceID))
+			return
+		}
+		identityCredentials, err := s.mint(userAssigned, request.CustomClaims)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		credentials.ExplicitIdentities = append(credentials.ExplicitIdentities, identityCredentials)
+	}
+
+	for _, resourceID := range request.DelegatedResources {
+		implicitIdentity, err := s.mint(newIdentity(resourceID), request.CustomClaims)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		credentials.DelegatedResources = append(credentials.DelegatedResources, dataplane.DelegatedResource{
+			DelegationID:     ptrTo(uuid.NewString()),
+			ImplicitIdentity: &implicitIdentity,
+			InternalID:       ptrTo(uuid.NewString()),
+			ResourceID:       ptrTo(resourceID),
+		})
+	}
+
+	writeJSON(w, credentials)
+}
+
+func (s *Server) deleteIdentity(w http.ResponseWriter) {
+	if s.systemAssigned == nil {
+		writeError(w, http.StatusNotFound, "NotFound", "the system-assigned identity has been deleted")
+		return
+	}
+	s.systemAssigned = nil
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) moveIdentity(w http.ResponseWriter, r *http.Request) {
+	var request dataplane.MoveIdentityRequest
+	if err := decodeBody(r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+		return
+	}
+	if request.TargetResourceID == nil || *request.TargetResourceID == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "targetResourceId is required")
+		return
+	}
+	s.resourceID = *request.TargetResourceID
+	writeJSON(w, dataplane.MoveIdentityResponse{
+		IdentityURL: ptrTo(s.identityURL()),
+	})
+}
+
+func (s *Server) systemAssignedCredentials() (dataplane.ManagedIdentityCredentials, error) {
+	credentials, err := s.mint(s.systemAssigned, nil)
+	if err != nil {
+		return dataplane.ManagedIdentityCredentials{}, err
+	}
+	return dataplane.ManagedIdentityCredentials{
+		AuthenticationEndpoint:     credentials.AuthenticationEndpoint,
+		CannotRenewAfter:           credentials.CannotRenewAfter,
+		ClientID:                   credentials.ClientID,
+		ClientSecret:               credentials.ClientSecret,
+		ClientSecretURL:            credentials.ClientSecretURL,
+		InternalID:                 ptrTo(uuid.NewString()),
+		MtlsAuthenticationEndpoint: credentials.MtlsAuthenticationEndpoint,
+		NotAfter:                   credentials.NotAfter,
+		NotBefore:                  credentials.NotBefore,
+		ObjectID:                   credentials.ObjectID,
+		RenewAfter:                 credentials.RenewAfter,
+		TenantID:                   credentials.TenantID,
+	}, nil
+}
+
+func (s *Server) mint(id *identity, claims *dataplane.CustomClaims) (dataplane.UserAssignedIdentityCredentials, error) {
+	credentials, err := NewUserAssignedIdentityCredentials(CredentialOptions{
+		TenantID:               s.tenantID,
+		ClientID:               id.clientID,
+		ObjectID:               id.objectID,
+		ResourceID:             id.resourceID,
+		AuthenticationEndpoint: s.authorityHost + "/",
+		ClientSecretURL:        s.identityURL(),
+		Times:                  s.times(),
+	})
+	if err != nil {
+		return dataplane.UserAssignedIdentityCredentials{}, err
+	}
+	credentials.CustomClaims = claims
+	return credentials, nil
+}
+
+func decodeBody(r *http.Request, into any) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	if err := json.Unmarshal(body, into); err != nil {
+		return fmt.Errorf("failed to decode request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(encoded)
+}
+
+type errorResponse struct {
+	Error errorResponseError `json:"error"`
+}
+
+type errorResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, statusCode int, code, message string) {
+	encoded, err := json.Marshal(errorResponse{Error: errorResponseError{Code: code, Message: message}})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(encoded)
+}
+
+func ptrTo[o any](s o) *o {
+	return &s
+}
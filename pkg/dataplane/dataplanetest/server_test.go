@@ -0,0 +1,170 @@
+package dataplanetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane"
+)
+
+type staticCredential struct {
+	tenantIDs []string
+}
+
+func (s *staticCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	s.tenantIDs = append(s.tenantIDs, options.TenantID)
+	return azcore.AccessToken{Token: "fake-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+const userAssignedID = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/uami"
+
+var testTimes = CredentialTimes{
+	NotBefore:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	NotAfter:         time.Date(2124, 1, 1, 0, 0, 0, 0, time.UTC),
+	RenewAfter:       time.Date(2074, 1, 1, 0, 0, 0, 0, time.UTC),
+	CannotRenewAfter: time.Date(2124, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// fixture is a fake server with one user-assigned identity and a client for it, so that every test starts afresh.
+type fixture struct {
+	server     *Server
+	credential *staticCredential
+	factory    dataplane.ClientFactory
+	client     dataplane.Client
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	server := NewServer(
+		WithUserAssignedIdentities(userAssignedID),
+		WithCredentialTimes(func() CredentialTimes { return testTimes }),
+	)
+	t.Cleanup(server.Close)
+
+	credential := &staticCredential{}
+	factory := dataplane.NewClientFactory(credential, "test-audience", server.ClientOptions())
+	client, err := factory.NewClient(server.IdentityURL())
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	return &fixture{server: server, credential: credential, factory: factory, client: client}
+}
+
+func TestServer(t *testing.T) {
+	t.Run("get system assigned identity credentials", func(t *testing.T) {
+		f := newFixture(t)
+		credentials, err := f.client.GetSystemAssignedIdentityCredentials(context.Background())
+		if err != nil {
+			t.Fatalf("error getting system assigned identity credentials: %v", err)
+		}
+		if len(f.credential.tenantIDs) == 0 {
+			t.Fatalf("expected a token request")
+		}
+		if diff := cmp.Diff(f.server.TenantID(), f.credential.tenantIDs[0]); diff != "" {
+			t.Errorf("unexpected tenant in token request (-want +got):\n%s", diff)
+		}
+		if _, err := dataplane.GetCredential(azcore.ClientOptions{}, dataplane.UserAssignedIdentityCredentials{
+			AuthenticationEndpoint: credentials.AuthenticationEndpoint,
+			ClientID:               credentials.ClientID,
+			ClientSecret:           credentials.ClientSecret,
+			TenantID:               credentials.TenantID,
+		}); err != nil {
+			t.Errorf("error building credential from system assigned identity credentials: %v", err)
+		}
+		name, parameters, err := dataplane.FormatManagedIdentityCredentialsForStorage("test", *credentials)
+		if err != nil {
+			t.Fatalf("error formatting credentials for storage: %v", err)
+		}
+		if name != "msi-test" {
+			t.Errorf("expected name %q, got %q", "msi-test", name)
+		}
+		if diff := cmp.Diff(testTimes.NotAfter, *parameters.SecretAttributes.Expires); diff != "" {
+			t.Errorf("unexpected expiry (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("get user assigned identity credentials", func(t *testing.T) {
+		f := newFixture(t)
+		credentials, err := f.client.GetUserAssignedIdentitiesCredentials(context.Background(), dataplane.UserAssignedIdentitiesRequest{
+			IdentityIDs: []string{userAssignedID},
+		})
+		if err != nil {
+			t.Fatalf("error getting user assigned identity credentials: %v", err)
+		}
+		if len(credentials.ExplicitIdentities) != 1 {
+			t.Fatalf("expected one explicit identity, got %d", len(credentials.ExplicitIdentities))
+		}
+		identity := credentials.ExplicitIdentities[0]
+		if diff := cmp.Diff(userAssignedID, *identity.ResourceID); diff != "" {
+			t.Errorf("unexpected resource ID (-want +got):\n%s", diff)
+		}
+		if _, err := dataplane.GetCredential(azcore.ClientOptions{}, identity); err != nil {
+			t.Errorf("error building credential from user assigned identity credentials: %v", err)
+		}
+		if _, _, err := dataplane.FormatUserAssignedIdentityCredentialsForStorage("test", identity); err != nil {
+			t.Errorf("error formatting credentials for storage: %v", err)
+		}
+	})
+
+	t.Run("get unknown user assigned identity credentials", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.client.GetUserAssignedIdentitiesCredentials(context.Background(), dataplane.UserAssignedIdentitiesRequest{
+			IdentityIDs: []string{"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/other"},
+		})
+		var responseErr *azcore.ResponseError
+		if !errors.As(err, &responseErr) || responseErr.StatusCode != http.StatusNotFound {
+			t.Errorf("expected a not found error, got %v", err)
+		}
+	})
+
+	t.Run("injected faults", func(t *testing.T) {
+		f := newFixture(t)
+		f.server.InjectFaults(Fault{StatusCode: http.StatusForbidden, Code: "Forbidden"})
+		_, err := f.client.GetSystemAssignedIdentityCredentials(context.Background())
+		var responseErr *azcore.ResponseError
+		if !errors.As(err, &responseErr) || responseErr.ErrorCode != "Forbidden" {
+			t.Errorf("expected the injected fault, got %v", err)
+		}
+	})
+
+	t.Run("move identity", func(t *testing.T) {
+		f := newFixture(t)
+		target := "/subscriptions/sub/resourcegroups/other/providers/Microsoft.Service/objects/object"
+		response, err := f.client.MoveIdentity(context.Background(), dataplane.MoveIdentityRequest{
+			TargetResourceID: &target,
+		})
+		if err != nil {
+			t.Fatalf("error moving identity: %v", err)
+		}
+		if !strings.Contains(*response.IdentityURL, target) {
+			t.Errorf("expected identity URL %q to contain target resource %q", *response.IdentityURL, target)
+		}
+		moved, err := f.factory.NewClient(*response.IdentityURL)
+		if err != nil {
+			t.Fatalf("error creating client: %v", err)
+		}
+		if _, err := moved.GetSystemAssignedIdentityCredentials(context.Background()); err != nil {
+			t.Errorf("error getting credentials from the moved identity: %v", err)
+		}
+	})
+
+	t.Run("delete system assigned identity", func(t *testing.T) {
+		f := newFixture(t)
+		if err := f.client.DeleteSystemAssignedIdentity(context.Background()); err != nil {
+			t.Fatalf("error deleting system assigned identity: %v", err)
+		}
+		_, err := f.client.GetSystemAssignedIdentityCredentials(context.Background())
+		var responseErr *azcore.ResponseError
+		if !errors.As(err, &responseErr) || responseErr.StatusCode != http.StatusNotFound {
+			t.Errorf("expected a not found error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package dataplane
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// encryptedCredentialsFormat identifies files holding credentials encrypted with AES-256-GCM.
+const encryptedCredentialsFormat = "msi-dataplane/aes-256-gcm/v1"
+
+var (
+	// ErrInvalidEncryptionKey is returned when a CredentialEncryptionKey is not a 32-byte AES-256 key, or when decryption
+	// keys lack an ID or share one.
+	ErrInvalidEncryptionKey = errors.New("invalid encryption key")
+	// ErrCredentialsNotEncrypted is returned when loading a plaintext credential file while decryption keys are configured.
+	ErrCredentialsNotEncrypted = errors.New("credential file is not encrypted")
+	// ErrDecryptCredentials is returned when an encrypted credential file cannot be decrypted with the configured keys.
+	ErrDecryptCredentials = errors.New("failed to decrypt credentials")
+	// ErrDecryptionKeysNotSupported is returned when decryption keys are configured for credentials that are not loaded
+	// from files, such as those stored in KeyVault.
+	ErrDecryptionKeysNotSupported = errors.New("decryption keys are only supported for credential files")
+)
+
+// CredentialEncryptionKey is a local key used to encrypt credential files at rest.
+type CredentialEncryptionKey struct {
+	// ID identifies the key in encrypted files, so that a file is decrypted with the key that encrypted it while keys are rotated.
+	ID string
+	// Key is the 32-byte AES-256 key.
+	Key []byte
+}
+
+func (k CredentialEncryptionKey) aead() (cipher.AEAD, error) {
+	if len(k.Key) != 32 {
+		return nil, fmt.Errorf("%w: key %q has %d bytes, not 32", ErrInvalidEncryptionKey, k.ID, len(k.Key))
+	}
+	block, err := aes.NewCipher(k.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedCredentials is the envelope of an encrypted credential file.
+type encryptedCredentials struct {
+	Format     string `json:"format"`
+	KeyID      string `json:"keyId"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// additionalData binds the ciphertext to the format and key it was encrypted for.
+func (e encryptedCredentials) additionalData() []byte {
+	return []byte(e.Format + "\n" + e.KeyID)
+}
+
+// WithDecryptionKeys configures reloading credentials to load files encrypted with one of the keys, as written by
+// WriteEncryptedUserAssignedIdentityCredentials or WriteEncryptedManagedIdentityCredentials. Files are decrypted in
+// memory. Once keys are configured, plaintext files are rejected with ErrCredentialsNotEncrypted. Every key must have
+// an ID of its own and be 32 bytes long, or creating the credential fails with ErrInvalidEncryptionKey. The keys are
+// copied, so the caller may clear them afterwards. Credentials loaded from KeyVault fail with
+// ErrDecryptionKeysNotSupported instead.
+func WithDecryptionKeys(keys ...CredentialEncryptionKey) Option {
+	return func(c *reloadingCredential) {
+		for _, key := range keys {
+			if err := key.validate(c.decryptionKeys); err != nil {
+				c.optionErr = errors.Join(c.optionErr, err)
+				continue
+			}
+			c.decryptionKeys = append(c.decryptionKeys, CredentialEncryptionKey{ID: key.ID, Key: bytes.Clone(key.Key)})
+		}
+	}
+}
+
+// validate ensures that the key can be used to decrypt credentials alongside the keys already configured.
+func (k CredentialEncryptionKey) validate(configured []CredentialEncryptionKey) error {
+	if k.ID == "" {
+		return fmt.Errorf("%w: key has no ID", ErrInvalidEncryptionKey)
+	}
+	if slices.ContainsFunc(configured, func(other CredentialEncryptionKey) bool { return other.ID == k.ID }) {
+		return fmt.Errorf("%w: more than one key has ID %q", ErrInvalidEncryptionKey, k.ID)
+	}
+	if len(k.Key) != 32 {
+		return fmt.Errorf("%w: key %q has %d bytes, not 32", ErrInvalidEncryptionKey, k.ID, len(k.Key))
+	}
+	return nil
+}
+
+// WriteEncryptedUserAssignedIdentityCredentials encrypts the credentials with the key and atomically writes them
+// to the file at path, for use with NewUserAssignedIdentityCredential or NewCredentialDirectory and WithDecryptionKeys.
+func WriteEncryptedUserAssignedIdentityCredentials(path string, credentials UserAssignedIdentityCredentials, key CredentialEncryptionKey) error {
+	return writeEncryptedFile(path, credentials, key)
+}
+
+// WriteEncryptedManagedIdentityCredentials encrypts the credentials with the key and atomically writes them
+// to the file at path, for use with NewManagedIdentityCredentialSet and WithDecryptionKeys.
+func WriteEncryptedManagedIdentityCredentials(path string, credentials ManagedIdentityCredentials, key CredentialEncryptionKey) error {
+	return writeEncryptedFile(path, credentials, key)
+}
+
+func writeEncryptedFile(path string, credentials any, key CredentialEncryptionKey) error {
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	aead, err := key.aead()
+	if err != nil {
+		return err
+	}
+	envelope := encryptedCredentials{
+		Format: encryptedCredentialsFormat,
+		KeyID:  key.ID,
+		Nonce:  make([]byte, aead.NonceSize()),
+	}
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	envelope.Ciphertext = aead.Seal(nil, envelope.Nonce, plaintext, envelope.additionalData())
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal encrypted credentials: %w", err)
+	}
+	return writeFileAtomically(path, raw)
+}
+
+// decodeCredentials unmarshals the content of a credential file, decrypting it first when decryption keys are configured.
+func (r *reloadingCredential) decodeCredentials(raw []byte, into any) error {
+	if len(r.decryptionKeys) > 0 {
+		plaintext, err := r.decrypt(raw)
+		if err != nil {
+			return err
+		}
+		raw = plaintext
+	}
+	return json.Unmarshal(raw, into)
+}
+
+func (r *reloadingCredential) decrypt(raw []byte) ([]byte, error) {
+	var envelope encryptedCredentials
+	if err := json.Unmarshal(raw, &envelope); err != nil || envelope.Format == "" {
+		return nil, ErrCredentialsNotEncrypted
+	}
+	if envelope.Format != encryptedCredentialsFormat {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrDecryptCredentials, envelope.Format)
+	}
+	// key IDs are unique, as WithDecryptionKeys rejects duplicates
+	i := slices.IndexFunc(r.decryptionKeys, func(key CredentialEncryptionKey) bool { return key.ID == envelope.KeyID })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no key with ID %q", ErrDecryptCredentials, envelope.KeyID)
+	}
+	aead, err := r.decryptionKeys[i].aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: nonce has %d bytes", ErrDecryptCredentials, len(envelope.Nonce))
+	}
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, envelope.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptCredentials, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,197 @@
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEncryptedCredentialFiles(t *testing.T) {
+	now := time.Now()
+	logger := logr.Discard()
+	current := CredentialEncryptionKey{ID: "current", Key: bytes.Repeat([]byte{1}, 32)}
+	previous := CredentialEncryptionKey{ID: "previous", Key: bytes.Repeat([]byte{2}, 32)}
+	impostor := CredentialEncryptionKey{ID: "current", Key: bytes.Repeat([]byte{3}, 32)}
+
+	for _, testCase := range []struct {
+		name     string
+		write    func(t *testing.T, path string, credentials UserAssignedIdentityCredentials)
+		keys     []CredentialEncryptionKey
+		expected error
+	}{
+		{
+			name: "encrypted",
+			write: func(t *testing.T, path string, credentials UserAssignedIdentityCredentials) {
+				if err := WriteEncryptedUserAssignedIdentityCredentials(path, credentials, current); err != nil {
+					t.Fatalf("failed to write encrypted credentials: %v", err)
+				}
+			},
+			keys: []CredentialEncryptionKey{current},
+		},
+		{
+			name: "encrypted with a previous key",
+			write: func(t *testing.T, path string, credentials UserAssignedIdentityCredentials) {
+				if err := WriteEncryptedUserAssignedIdentityCredentials(path, credentials, previous); err != nil {
+					t.Fatalf("failed to write encrypted credentials: %v", err)
+				}
+			},
+			keys: []CredentialEncryptionKey{current, previous},
+		},
+		{
+			name: "encrypted with an unknown key",
+			write: func(t *testing.T, path string, credentials UserAssignedIdentityCredentials) {
+				if err := WriteEncryptedUserAssignedIdentityCredentials(path, credentials, previous); err != nil {
+					t.Fatalf("failed to write encrypted credentials: %v", err)
+				}
+			},
+			keys:     []CredentialEncryptionKey{current},
+			expected: ErrDecryptCredentials,
+		},
+		{
+			name: "encrypted with another key of the same ID",
+			write: func(t *testing.T, path string, credentials UserAssignedIdentityCredentials) {
+				if err := WriteEncryptedUserAssignedIdentityCredentials(path, credentials, impostor); err != nil {
+					t.Fatalf("failed to write encrypted credentials: %v", err)
+				}
+			},
+			keys:     []CredentialEncryptionKey{current},
+			expected: ErrDecryptCredentials,
+		},
+		{
+			name:     "plaintext",
+			write:    writeCredentialsFile,
+			keys:     []CredentialEncryptionKey{current},
+			expected: ErrCredentialsNotEncrypted,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			path := filepath.Join(t.TempDir(), "credential.json")
+			credentials := testUserAssignedIdentityCredentials(t, now.Add(-time.Hour))
+			testCase.write(t, path, credentials)
+
+			credential, err := NewUserAssignedIdentityCredential(ctx, path, WithLogger(&logger), WithDecryptionKeys(testCase.keys...))
+			if testCase.expected != nil {
+				if !errors.Is(err, testCase.expected) {
+					t.Errorf("expected %v, got %v", testCase.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create credential: %v", err)
+			}
+			defer func() {
+				if err := credential.(*reloadingCredential).Close(); err != nil {
+					t.Errorf("failed to close credential: %v", err)
+				}
+			}()
+			if status := credential.(*reloadingCredential).Status(); status.Current == nil || status.Current.ClientID != *credentials.ClientID {
+				t.Errorf("expected the encrypted credentials to be loaded, got %+v", status.Current)
+			}
+		})
+	}
+}
+
+func TestWithDecryptionKeys(t *testing.T) {
+	key := func(id string, size int) CredentialEncryptionKey {
+		return CredentialEncryptionKey{ID: id, Key: bytes.Repeat([]byte{1}, size)}
+	}
+	for _, testCase := range []struct {
+		name  string
+		keys  [][]CredentialEncryptionKey
+		valid bool
+	}{
+		{name: "valid", keys: [][]CredentialEncryptionKey{{key("current", 32), key("previous", 32)}}, valid: true},
+		{name: "no ID", keys: [][]CredentialEncryptionKey{{key("", 32)}}},
+		{name: "duplicate ID", keys: [][]CredentialEncryptionKey{{key("current", 32), key("current", 32)}}},
+		{name: "duplicate ID across options", keys: [][]CredentialEncryptionKey{{key("current", 32)}, {key("current", 32)}}},
+		{name: "short key", keys: [][]CredentialEncryptionKey{{key("current", 16)}}},
+		{name: "long key", keys: [][]CredentialEncryptionKey{{key("current", 64)}}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var opts []Option
+			for _, keys := range testCase.keys {
+				opts = append(opts, WithDecryptionKeys(keys...))
+			}
+			_, err := newReloadingCredential(time.Hour, opts...)
+			if testCase.valid && err != nil {
+				t.Errorf("expected keys to be accepted, got %v", err)
+			}
+			if !testCase.valid && !errors.Is(err, ErrInvalidEncryptionKey) {
+				t.Errorf("expected keys to be rejected, got %v", err)
+			}
+		})
+	}
+
+	t.Run("keys are copied", func(t *testing.T) {
+		original := key("current", 32)
+		credential, err := newReloadingCredential(time.Hour, WithDecryptionKeys(original))
+		if err != nil {
+			t.Fatalf("failed to create credential: %v", err)
+		}
+		clear(original.Key)
+		if diff := cmp.Diff(bytes.Repeat([]byte{1}, 32), credential.decryptionKeys[0].Key); diff != "" {
+			t.Errorf("expected the key to be kept as configured (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestKeyVaultCredentialRejectsDecryptionKeys(t *testing.T) {
+	getter := &fakeSecretGetter{}
+	getter.set(t, "first", testUserAssignedIdentityCredentials(t, time.Now().Add(-time.Hour)))
+	logger := logr.Discard()
+	key := CredentialEncryptionKey{ID: "key", Key: bytes.Repeat([]byte{1}, 32)}
+	if _, err := newKeyVaultReloadingCredential(context.Background(), getter, "uamsi-test", WithLogger(&logger), WithDecryptionKeys(key)); !errors.Is(err, ErrDecryptionKeysNotSupported) {
+		t.Errorf("expected decryption keys to be rejected, got %v", err)
+	}
+	if calls := getter.callCount(); calls != 0 {
+		t.Errorf("expected no secret to be fetched, got %d calls", calls)
+	}
+}
+
+func TestWriteEncryptedCredentials(t *testing.T) {
+	dir := t.TempDir()
+	credentials := testManagedIdentityCredentials(t, time.Now().Add(-time.Hour), true)
+
+	if err := WriteEncryptedManagedIdentityCredentials(filepath.Join(dir, "short.json"), credentials, CredentialEncryptionKey{ID: "short", Key: []byte("too short")}); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Errorf("expected an invalid key error, got %v", err)
+	}
+
+	key := CredentialEncryptionKey{ID: "key", Key: bytes.Repeat([]byte{1}, 32)}
+	path := filepath.Join(dir, "credentials.json")
+	if err := WriteEncryptedManagedIdentityCredentials(path, credentials, key); err != nil {
+		t.Fatalf("failed to write encrypted credentials: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read encrypted credentials: %v", err)
+	}
+	if bytes.Contains(raw, []byte(*credentials.ClientSecret)) {
+		t.Errorf("expected the client secret not to be written in plaintext")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := logr.Discard()
+	set, err := NewManagedIdentityCredentialSet(ctx, path, WithLogger(&logger), WithDecryptionKeys(key))
+	if err != nil {
+		t.Fatalf("failed to create credential set: %v", err)
+	}
+	defer func() {
+		if err := set.Close(); err != nil {
+			t.Errorf("failed to close credential set: %v", err)
+		}
+	}()
+	if _, err := set.ForClientID("delegated-client"); err != nil {
+		t.Errorf("expected the encrypted identities to be loaded, got %v", err)
+	}
+}
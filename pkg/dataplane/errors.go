@@ -0,0 +1,123 @@
+package dataplane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/internal/client"
+)
+
+// Sentinel errors for the classes of failure described by the MSI data plane specification. Errors returned
+// from a Client can be compared to these with errors.Is.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	// ErrIdentityNotFound matches every 404, as the specification documents no code that tells a missing identity,
+	// such as one that was deleted, apart from a request to the wrong URL or API version.
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrThrottled        = errors.New("too many requests")
+	ErrServerError      = errors.New("server error")
+)
+
+const requestIDHeader = "x-ms-request-id"
+
+// MSIError is returned from a Client when the MSI data plane responds with an error.
+type MSIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code and Message are reported by the service in the body of the response, when present.
+	Code    string
+	Message string
+	// RequestID is the x-ms-request-id of the response, useful when raising issues with the service.
+	RequestID string
+	// RawResponse is the response from the service.
+	RawResponse *http.Response
+
+	cause error
+}
+
+func (e *MSIError) Error() string {
+	var description strings.Builder
+	fmt.Fprintf(&description, "MSI data plane request failed with status %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&description, " (%s)", e.Code)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&description, ": %s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&description, ", request ID %s", e.RequestID)
+	}
+	return description.String()
+}
+
+// Is allows for errors.Is to classify the error by the status code of the response.
+func (e *MSIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound, ErrIdentityNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrMethodNotAllowed:
+		return e.StatusCode == http.StatusMethodNotAllowed
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= http.StatusInternalServerError && e.StatusCode < 600
+	}
+	return false
+}
+
+// Unwrap exposes the underlying *azcore.ResponseError.
+func (e *MSIError) Unwrap() error {
+	return e.cause
+}
+
+// asMSIError converts errors for responses from the service into an *MSIError, passing others through unchanged.
+func asMSIError(err error) error {
+	var responseErr *azcore.ResponseError
+	if !errors.As(err, &responseErr) {
+		return err
+	}
+
+	msiErr := &MSIError{
+		StatusCode:  responseErr.StatusCode,
+		Code:        responseErr.ErrorCode,
+		RawResponse: responseErr.RawResponse,
+		cause:       err,
+	}
+	if responseErr.RawResponse == nil {
+		return msiErr
+	}
+	msiErr.RequestID = responseErr.RawResponse.Header.Get(requestIDHeader)
+
+	// the payload is cached when the response error is created, so we can read it again here
+	body, payloadErr := runtime.Payload(responseErr.RawResponse)
+	if payloadErr != nil || len(body) == 0 {
+		return msiErr
+	}
+	var errorResponse client.ErrorResponse
+	if json.Unmarshal(body, &errorResponse) != nil || errorResponse.Error == nil {
+		return msiErr
+	}
+	if errorResponse.Error.Code != nil && *errorResponse.Error.Code != "" {
+		msiErr.Code = *errorResponse.Error.Code
+	}
+	if errorResponse.Error.Message != nil {
+		msiErr.Message = *errorResponse.Error.Message
+	}
+	return msiErr
+}
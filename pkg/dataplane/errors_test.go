@@ -0,0 +1,144 @@
+package dataplane
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestAsMSIError(t *testing.T) {
+	allSentinels := []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrIdentityNotFound, ErrMethodNotAllowed, ErrThrottled, ErrServerError}
+
+	for _, testCase := range []struct {
+		name       string
+		statusCode int
+		body       string
+		expected   *MSIError
+		sentinels  []error
+	}{
+		{
+			name:       "bad request with error body",
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":{"code":"InvalidRequest","message":"the request was malformed"}}`,
+			expected:   &MSIError{StatusCode: http.StatusBadRequest, Code: "InvalidRequest", Message: "the request was malformed", RequestID: "request-id"},
+			sentinels:  []error{ErrBadRequest},
+		},
+		{
+			name:       "unauthorized without body",
+			statusCode: http.StatusUnauthorized,
+			expected:   &MSIError{StatusCode: http.StatusUnauthorized, RequestID: "request-id"},
+			sentinels:  []error{ErrUnauthorized},
+		},
+		{
+			name:       "forbidden",
+			statusCode: http.StatusForbidden,
+			body:       `{"error":{"code":"Forbidden"}}`,
+			expected:   &MSIError{StatusCode: http.StatusForbidden, Code: "Forbidden", RequestID: "request-id"},
+			sentinels:  []error{ErrForbidden},
+		},
+		{
+			name:       "not found",
+			statusCode: http.StatusNotFound,
+			body:       `{"error":{"code":"NotFound","message":"no such identity"}}`,
+			expected:   &MSIError{StatusCode: http.StatusNotFound, Code: "NotFound", Message: "no such identity", RequestID: "request-id"},
+			sentinels:  []error{ErrNotFound, ErrIdentityNotFound},
+		},
+		{
+			name:       "not found for another reason",
+			statusCode: http.StatusNotFound,
+			body:       `{"error":{"code":"InvalidResourceType","message":"no route to the resource"}}`,
+			expected:   &MSIError{StatusCode: http.StatusNotFound, Code: "InvalidResourceType", Message: "no route to the resource", RequestID: "request-id"},
+			sentinels:  []error{ErrNotFound, ErrIdentityNotFound},
+		},
+		{
+			name:       "not found without body",
+			statusCode: http.StatusNotFound,
+			expected:   &MSIError{StatusCode: http.StatusNotFound, RequestID: "request-id"},
+			sentinels:  []error{ErrNotFound, ErrIdentityNotFound},
+		},
+		{
+			name:       "method not allowed",
+			statusCode: http.StatusMethodNotAllowed,
+			expected:   &MSIError{StatusCode: http.StatusMethodNotAllowed, RequestID: "request-id"},
+			sentinels:  []error{ErrMethodNotAllowed},
+		},
+		{
+			name:       "throttled",
+			statusCode: http.StatusTooManyRequests,
+			expected:   &MSIError{StatusCode: http.StatusTooManyRequests, RequestID: "request-id"},
+			sentinels:  []error{ErrThrottled},
+		},
+		{
+			name:       "server error with malformed body",
+			statusCode: http.StatusServiceUnavailable,
+			body:       `not json`,
+			expected:   &MSIError{StatusCode: http.StatusServiceUnavailable, RequestID: "request-id"},
+			sentinels:  []error{ErrServerError},
+		},
+		{
+			name:       "error code outside the error envelope",
+			statusCode: http.StatusInternalServerError,
+			body:       `{"code":"whatever"}`,
+			expected:   &MSIError{StatusCode: http.StatusInternalServerError, Code: "whatever", RequestID: "request-id"},
+			sentinels:  []error{ErrServerError},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://example.com/identity", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			header := http.Header{}
+			header.Set(requestIDHeader, "request-id")
+			resp := &http.Response{
+				StatusCode: testCase.statusCode,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(testCase.body)),
+				Request:    req,
+			}
+			responseErr := runtime.NewResponseError(resp)
+
+			err = asMSIError(fmt.Errorf("wrapped: %w", responseErr))
+			var msiErr *MSIError
+			if !errors.As(err, &msiErr) {
+				t.Fatalf("expected an *MSIError, got %T", err)
+			}
+			if diff := cmp.Diff(testCase.expected, msiErr, cmpopts.IgnoreFields(MSIError{}, "RawResponse"), cmpopts.IgnoreUnexported(MSIError{})); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+			if msiErr.RawResponse != resp {
+				t.Errorf("expected the raw response to be exposed")
+			}
+
+			for _, sentinel := range allSentinels {
+				if got, want := errors.Is(err, sentinel), slices.Contains(testCase.sentinels, sentinel); got != want {
+					t.Errorf("errors.Is(err, %v) = %v, expected %v", sentinel, got, want)
+				}
+			}
+
+			var unwrapped *azcore.ResponseError
+			if !errors.As(err, &unwrapped) || unwrapped.StatusCode != testCase.statusCode {
+				t.Errorf("expected the *azcore.ResponseError to be available, got %v", unwrapped)
+			}
+		})
+	}
+
+	t.Run("other errors pass through", func(t *testing.T) {
+		original := errors.New("connection refused")
+		if err := asMSIError(original); err != original {
+			t.Errorf("expected the error to be passed through, got %v", err)
+		}
+		if err := asMSIError(nil); err != nil {
+			t.Errorf("expected nil to be passed through, got %v", err)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package dataplane
 
 import (
+	"crypto"
+	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -51,16 +53,24 @@ func GetCredential(clientOpts azcore.ClientOptions, credential UserAssignedIdent
 	// https://eng.ms/docs/products/arm/rbac/managed_identities/msionboardingcredentialapiversion2019-08-31
 	opts.Cloud.ActiveDirectoryAuthorityHost = *credential.AuthenticationEndpoint
 
-	// Parse the certificate and private key from the base64 encoded secret
-	decodedSecret, err := base64.StdEncoding.DecodeString(*credential.ClientSecret)
+	crt, key, err := parseClientSecret(*credential.ClientSecret)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", errDecodeClientSecret, err)
+		return nil, err
+	}
+	return azidentity.NewClientCertificateCredential(*credential.TenantID, *credential.ClientID, crt, key, opts)
+}
+
+// parseClientSecret parses the certificate and private key from the base64 encoded secret.
+func parseClientSecret(clientSecret string) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	decodedSecret, err := base64.StdEncoding.DecodeString(clientSecret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errDecodeClientSecret, err)
 	}
 	// Note - ParseCertificates does not currently support pkcs12 SHA256 MAC certs, so if
 	// managed identity team changes the cert format, double check this code
 	crt, key, err := azidentity.ParseCertificates(decodedSecret, nil)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", errParseCertificate, err)
+		return nil, nil, fmt.Errorf("%w: %w", errParseCertificate, err)
 	}
-	return azidentity.NewClientCertificateCredential(*credential.TenantID, *credential.ClientID, crt, key, opts)
+	return crt, key, nil
 }
@@ -0,0 +1,54 @@
+package selfsigned
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// NewClientSecret mints a self-signed certificate valid between notBefore and notAfter and returns
+// it, along with its private key, as a base64-encoded PKCS#12 archive with no password - the same
+// encoding the MSI data plane uses for the client_secret field of credentials.
+func NewClientSecret(commonName string, notBefore, notAfter time.Time) (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create certificate: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	// the legacy encoding is the only one golang.org/x/crypto/pkcs12, and therefore azidentity, can decode
+	archive, err := pkcs12.LegacyDES.Encode(key, certificate, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode PKCS#12 archive: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(archive), nil
+}
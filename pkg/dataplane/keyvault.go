@@ -2,7 +2,9 @@ package dataplane
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
@@ -28,20 +30,9 @@ func IdentifierForUserAssignedIdentityCredentials(identifier string) string {
 // managed identity credentials, ensuring that appropriate times are recorded for the expiry and notBefore,
 // as well as that renewal times are recorded in tags.
 func FormatManagedIdentityCredentialsForStorage(identifier string, credentials ManagedIdentityCredentials) (string, azsecrets.SetSecretParameters, error) {
-	var rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter *string
-	switch len(credentials.ExplicitIdentities) {
-	case 0:
-		rawNotAfter = credentials.NotAfter
-		rawNotBefore = credentials.NotBefore
-		rawRenewAfter = credentials.RenewAfter
-		rawCannotRenewAfter = credentials.CannotRenewAfter
-	case 1:
-		rawNotAfter = credentials.ExplicitIdentities[0].NotAfter
-		rawNotBefore = credentials.ExplicitIdentities[0].NotBefore
-		rawRenewAfter = credentials.ExplicitIdentities[0].RenewAfter
-		rawCannotRenewAfter = credentials.ExplicitIdentities[0].CannotRenewAfter
-	default:
-		return "", azsecrets.SetSecretParameters{}, fmt.Errorf("assumption violated, found %d explicit identities, expected none, or one", len(credentials.ExplicitIdentities))
+	rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter, err := managedIdentityCredentialsTimestamps(credentials)
+	if err != nil {
+		return "", azsecrets.SetSecretParameters{}, err
 	}
 
 	parameters, err := keyVaultParameters(credentials, rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter)
@@ -52,6 +43,20 @@ func FormatManagedIdentityCredentialsForStorage(identifier string, credentials M
 	return IdentifierForManagedIdentityCredentials(identifier), parameters, nil
 }
 
+// managedIdentityCredentialsTimestamps determines which timestamps describe the credentials: those of the
+// system-assigned identity, or those of the one explicit identity, if one was requested.
+func managedIdentityCredentialsTimestamps(credentials ManagedIdentityCredentials) (rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter *string, err error) {
+	switch len(credentials.ExplicitIdentities) {
+	case 0:
+		return credentials.NotAfter, credentials.NotBefore, credentials.RenewAfter, credentials.CannotRenewAfter, nil
+	case 1:
+		identity := credentials.ExplicitIdentities[0]
+		return identity.NotAfter, identity.NotBefore, identity.RenewAfter, identity.CannotRenewAfter, nil
+	default:
+		return nil, nil, nil, nil, fmt.Errorf("assumption violated, found %d explicit identities, expected none, or one", len(credentials.ExplicitIdentities))
+	}
+}
+
 func keyVaultParameters(credentials any, rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter *string) (azsecrets.SetSecretParameters, error) {
 	for key, value := range map[string]*string{
 		"NotAfter":         rawNotAfter,
@@ -106,3 +111,115 @@ func FormatUserAssignedIdentityCredentialsForStorage(identifier string, credenti
 
 	return IdentifierForUserAssignedIdentityCredentials(identifier), parameters, nil
 }
+
+var (
+	// ErrUnexpectedStoragePrefix is returned when parsing stored credentials whose name does not carry
+	// the prefix expected for the type of credentials being parsed.
+	ErrUnexpectedStoragePrefix = errors.New("stored credentials do not have the expected prefix")
+)
+
+// StoredTimestampMismatchError is returned when parsing stored credentials whose tags or attributes
+// disagree with the timestamps embedded in the credentials themselves.
+type StoredTimestampMismatchError struct {
+	// Field is the tag or attribute that disagrees.
+	Field string
+	// Stored is the value recorded in the tag or attribute, empty if none was recorded.
+	Stored string
+	// Embedded is the value embedded in the credentials.
+	Embedded string
+}
+
+func (e *StoredTimestampMismatchError) Error() string {
+	return fmt.Sprintf("stored %s %q does not match %q embedded in credentials", e.Field, e.Stored, e.Embedded)
+}
+
+// ParseManagedIdentityCredentialsFromStorage is the inverse of FormatManagedIdentityCredentialsForStorage, parsing
+// managed identity credentials from a KeyVault secret and ensuring that the times recorded in its attributes and
+// tags match those in the credentials.
+func ParseManagedIdentityCredentialsFromStorage(secret azsecrets.GetSecretResponse) (ManagedIdentityCredentials, error) {
+	var credentials ManagedIdentityCredentials
+	if err := parseStoredCredentials(secret.Secret, ManagedIdentityCredentialsStoragePrefix, &credentials); err != nil {
+		return ManagedIdentityCredentials{}, err
+	}
+	rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter, err := managedIdentityCredentialsTimestamps(credentials)
+	if err != nil {
+		return ManagedIdentityCredentials{}, err
+	}
+	if err := validateStoredTimestamps(secret.Secret, rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter); err != nil {
+		return ManagedIdentityCredentials{}, err
+	}
+	return credentials, nil
+}
+
+// ParseUserAssignedIdentityCredentialsFromStorage is the inverse of FormatUserAssignedIdentityCredentialsForStorage,
+// parsing user-assigned managed identity credentials from a KeyVault secret and ensuring that the times recorded
+// in its attributes and tags match those in the credentials.
+func ParseUserAssignedIdentityCredentialsFromStorage(secret azsecrets.GetSecretResponse) (UserAssignedIdentityCredentials, error) {
+	var credentials UserAssignedIdentityCredentials
+	if err := parseStoredCredentials(secret.Secret, UserAssignedIdentityCredentialsStoragePrefix, &credentials); err != nil {
+		return UserAssignedIdentityCredentials{}, err
+	}
+	if err := validateStoredTimestamps(secret.Secret, credentials.NotAfter, credentials.NotBefore, credentials.RenewAfter, credentials.CannotRenewAfter); err != nil {
+		return UserAssignedIdentityCredentials{}, err
+	}
+	return credentials, nil
+}
+
+func parseStoredCredentials(secret azsecrets.Secret, prefix string, into any) error {
+	if secret.ID == nil {
+		return errors.New("assumption violated, secret ID was nil")
+	}
+	if name := secret.ID.Name(); !strings.HasPrefix(name, prefix) {
+		return fmt.Errorf("%w: expected %q to begin with %q", ErrUnexpectedStoragePrefix, name, prefix)
+	}
+	if secret.Value == nil {
+		return errors.New("assumption violated, secret value was nil")
+	}
+	if err := json.Unmarshal([]byte(*secret.Value), into); err != nil {
+		return fmt.Errorf("failed to unmarshal credentials: %w", err)
+	}
+	return nil
+}
+
+func validateStoredTimestamps(secret azsecrets.Secret, rawNotAfter, rawNotBefore, rawRenewAfter, rawCannotRenewAfter *string) error {
+	var attributes azsecrets.SecretAttributes
+	if secret.Attributes != nil {
+		attributes = *secret.Attributes
+	}
+	for _, check := range []struct {
+		field    string
+		stored   *string
+		embedded *string
+	}{
+		{field: "expires", stored: formatStoredTime(attributes.Expires), embedded: rawNotAfter},
+		{field: "not_before", stored: formatStoredTime(attributes.NotBefore), embedded: rawNotBefore},
+		{field: RenewAfterKeyVaultTag, stored: secret.Tags[RenewAfterKeyVaultTag], embedded: rawRenewAfter},
+		{field: CannotRenewAfterKeyVaultTag, stored: secret.Tags[CannotRenewAfterKeyVaultTag], embedded: rawCannotRenewAfter},
+	} {
+		if check.embedded == nil {
+			return fmt.Errorf("assumption violated, credentials had no value for %q", check.field)
+		}
+		embedded, err := time.Parse(time.RFC3339, *check.embedded)
+		if err != nil {
+			return err
+		}
+		mismatch := &StoredTimestampMismatchError{Field: check.field, Embedded: *check.embedded}
+		if check.stored == nil {
+			return mismatch
+		}
+		mismatch.Stored = *check.stored
+		stored, err := time.Parse(time.RFC3339, *check.stored)
+		// KeyVault records attributes with a resolution of seconds
+		if err != nil || !stored.Truncate(time.Second).Equal(embedded.Truncate(time.Second)) {
+			return mismatch
+		}
+	}
+	return nil
+}
+
+func formatStoredTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	return ptrTo(t.UTC().Format(time.RFC3339))
+}
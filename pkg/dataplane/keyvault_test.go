@@ -1,6 +1,7 @@
 package dataplane
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -226,3 +227,137 @@ func TestFormatUserAssignedIdentityCredentialsForStorage(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCredentialsFromStorage(t *testing.T) {
+	userAssigned := UserAssignedIdentityCredentials{
+		ClientSecretURL:  ptrTo("whatever"),
+		CannotRenewAfter: ptrTo("2023-01-02T15:04:05Z"),
+		NotAfter:         ptrTo("2006-01-02T15:04:05Z"),
+		NotBefore:        ptrTo("2001-01-02T15:04:05Z"),
+		RenewAfter:       ptrTo("2003-01-02T15:04:05Z"),
+	}
+	managedIdentity := ManagedIdentityCredentials{
+		ExplicitIdentities: []UserAssignedIdentityCredentials{userAssigned},
+	}
+
+	secretFor := func(name string, parameters azsecrets.SetSecretParameters, mutate func(*azsecrets.Secret)) azsecrets.GetSecretResponse {
+		secret := azsecrets.Secret{
+			ID:         ptrTo(azsecrets.ID("https://vault.vault.azure.net/secrets/" + name + "/version")),
+			Value:      parameters.Value,
+			Attributes: parameters.SecretAttributes,
+			Tags:       map[string]*string{},
+		}
+		for key, value := range parameters.Tags {
+			secret.Tags[key] = value
+		}
+		if mutate != nil {
+			mutate(&secret)
+		}
+		return azsecrets.GetSecretResponse{Secret: secret}
+	}
+
+	managedIdentityName, managedIdentityParameters, err := FormatManagedIdentityCredentialsForStorage("test", managedIdentity)
+	if err != nil {
+		t.Fatalf("failed to format managed identity credentials: %v", err)
+	}
+	userAssignedName, userAssignedParameters, err := FormatUserAssignedIdentityCredentialsForStorage("test", userAssigned)
+	if err != nil {
+		t.Fatalf("failed to format user-assigned identity credentials: %v", err)
+	}
+
+	t.Run("managed identity credentials", func(t *testing.T) {
+		for _, testCase := range []struct {
+			name     string
+			secret   azsecrets.GetSecretResponse
+			expected ManagedIdentityCredentials
+			err      error
+		}{
+			{
+				name:     "round trip",
+				secret:   secretFor(managedIdentityName, managedIdentityParameters, nil),
+				expected: managedIdentity,
+			},
+			{
+				name:   "wrong prefix",
+				secret: secretFor(userAssignedName, managedIdentityParameters, nil),
+				err:    ErrUnexpectedStoragePrefix,
+			},
+			{
+				name: "renewal tag disagrees",
+				secret: secretFor(managedIdentityName, managedIdentityParameters, func(secret *azsecrets.Secret) {
+					secret.Tags[RenewAfterKeyVaultTag] = ptrTo("2004-01-02T15:04:05Z")
+				}),
+				err: &StoredTimestampMismatchError{Field: RenewAfterKeyVaultTag, Stored: "2004-01-02T15:04:05Z", Embedded: "2003-01-02T15:04:05Z"},
+			},
+		} {
+			t.Run(testCase.name, func(t *testing.T) {
+				credentials, err := ParseManagedIdentityCredentialsFromStorage(testCase.secret)
+				if !matchesError(err, testCase.err) {
+					t.Fatalf("expected error %v, got %v", testCase.err, err)
+				}
+				if diff := cmp.Diff(testCase.expected, credentials); diff != "" {
+					t.Errorf("unexpected credentials (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("user-assigned identity credentials", func(t *testing.T) {
+		for _, testCase := range []struct {
+			name     string
+			secret   azsecrets.GetSecretResponse
+			expected UserAssignedIdentityCredentials
+			err      error
+		}{
+			{
+				name:     "round trip",
+				secret:   secretFor(userAssignedName, userAssignedParameters, nil),
+				expected: userAssigned,
+			},
+			{
+				name:   "wrong prefix",
+				secret: secretFor(managedIdentityName, userAssignedParameters, nil),
+				err:    ErrUnexpectedStoragePrefix,
+			},
+			{
+				name: "missing tag",
+				secret: secretFor(userAssignedName, userAssignedParameters, func(secret *azsecrets.Secret) {
+					delete(secret.Tags, CannotRenewAfterKeyVaultTag)
+				}),
+				err: &StoredTimestampMismatchError{Field: CannotRenewAfterKeyVaultTag, Embedded: "2023-01-02T15:04:05Z"},
+			},
+			{
+				name: "expiry disagrees",
+				secret: secretFor(userAssignedName, userAssignedParameters, func(secret *azsecrets.Secret) {
+					secret.Attributes = &azsecrets.SecretAttributes{
+						Expires:   ptrTo(time.Date(2007, 1, 2, 15, 4, 5, 0, time.UTC)),
+						NotBefore: secret.Attributes.NotBefore,
+					}
+				}),
+				err: &StoredTimestampMismatchError{Field: "expires", Stored: "2007-01-02T15:04:05Z", Embedded: "2006-01-02T15:04:05Z"},
+			},
+		} {
+			t.Run(testCase.name, func(t *testing.T) {
+				credentials, err := ParseUserAssignedIdentityCredentialsFromStorage(testCase.secret)
+				if !matchesError(err, testCase.err) {
+					t.Fatalf("expected error %v, got %v", testCase.err, err)
+				}
+				if diff := cmp.Diff(testCase.expected, credentials); diff != "" {
+					t.Errorf("unexpected credentials (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+}
+
+// matchesError determines if got is the expected error, comparing typed errors by value.
+func matchesError(got, want error) bool {
+	if want == nil || got == nil {
+		return want == got
+	}
+	var mismatch *StoredTimestampMismatchError
+	if wantMismatch, ok := want.(*StoredTimestampMismatchError); ok {
+		return errors.As(got, &mismatch) && *mismatch == *wantMismatch
+	}
+	return errors.Is(got, want)
+}
@@ -0,0 +1,197 @@
+package dataplane
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+// prefetchRetryDelay is how long to wait before fetching a token again after failing to.
+const prefetchRetryDelay = 30 * time.Second
+
+// WithTokenPrefetch fetches tokens for each of the scopes in the background, whenever credentials are loaded and
+// again refreshBefore the tokens expire, so that requests for a token with one of the scopes are served from memory.
+// Requests for other scopes, or that set a tenant, claims or CAE, are passed through to the credential as usual.
+func WithTokenPrefetch(refreshBefore time.Duration, scopes ...string) Option {
+	return func(c *reloadingCredential) {
+		c.prefetch = &tokenPrefetch{
+			scopes:        scopes,
+			refreshBefore: refreshBefore,
+			tokens:        map[string]prefetchedToken{},
+		}
+	}
+}
+
+// tokenPrefetch holds the tokens fetched ahead of time for a reloading credential.
+type tokenPrefetch struct {
+	scopes        []string
+	refreshBefore time.Duration
+
+	lock   sync.RWMutex
+	tokens map[string]prefetchedToken
+}
+
+// prefetchedToken is a token for a scope, along with the credential that fetched it.
+type prefetchedToken struct {
+	source *azidentity.ClientCertificateCredential
+	token  azcore.AccessToken
+}
+
+// forIdentity creates a new, empty, prefetch configured like this one.
+func (p *tokenPrefetch) forIdentity() *tokenPrefetch {
+	if p == nil {
+		return nil
+	}
+	return &tokenPrefetch{
+		scopes:        p.scopes,
+		refreshBefore: p.refreshBefore,
+		tokens:        map[string]prefetchedToken{},
+	}
+}
+
+// cached returns the token fetched ahead of time by the credential for the request, if there is one that is fresh.
+func (p *tokenPrefetch) cached(now time.Time, source *azidentity.ClientCertificateCredential, options policy.TokenRequestOptions) (azcore.AccessToken, bool) {
+	if p == nil || len(options.Scopes) != 1 || options.TenantID != "" || options.Claims != "" || options.EnableCAE {
+		return azcore.AccessToken{}, false
+	}
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	prefetched, ok := p.tokens[options.Scopes[0]]
+	if !ok || prefetched.source != source || !p.fresh(now, prefetched.token) {
+		return azcore.AccessToken{}, false
+	}
+	return prefetched.token, true
+}
+
+func (p *tokenPrefetch) fresh(now time.Time, token azcore.AccessToken) bool {
+	return now.Before(token.ExpiresOn.Add(-p.refreshBefore))
+}
+
+// prefetchTokens fetches tokens for the scopes that have none fresh for the current credential, returning the time
+// until they must be fetched again. When prefetching is not enabled, it returns false.
+func (r *reloadingCredential) prefetchTokens(ctx context.Context) (time.Duration, bool) {
+	p := r.prefetch
+	if p == nil || len(p.scopes) == 0 {
+		return 0, false
+	}
+	r.lock.RLock()
+	source := r.currentValue
+	r.lock.RUnlock()
+	if source == nil {
+		return prefetchRetryDelay, true
+	}
+
+	next := time.Duration(-1)
+	for _, scope := range p.scopes {
+		p.lock.RLock()
+		prefetched, ok := p.tokens[scope]
+		p.lock.RUnlock()
+
+		if !ok || prefetched.source != source || !p.fresh(r.clock.Now(), prefetched.token) {
+			token, err := source.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}})
+			if err != nil {
+				if ctx.Err() == nil {
+					r.logger.Error(err, "failed to prefetch token", "scope", scope)
+				}
+				next = earliest(next, prefetchRetryDelay)
+				continue
+			}
+			prefetched = prefetchedToken{source: source, token: token}
+			p.lock.Lock()
+			p.tokens[scope] = prefetched
+			p.lock.Unlock()
+		}
+		next = earliest(next, r.clock.Until(prefetched.token.ExpiresOn.Add(-p.refreshBefore)))
+	}
+	// don't spin when a token is issued with a lifetime shorter than refreshBefore
+	return max(next, time.Second), true
+}
+
+func earliest(current, candidate time.Duration) time.Duration {
+	if current < 0 {
+		return candidate
+	}
+	return min(current, candidate)
+}
+
+// prefetchTimer fires when tokens should next be prefetched. It never fires before it is first reset.
+type prefetchTimer struct {
+	clock clock.Clock
+	timer clock.Timer
+}
+
+func (t *prefetchTimer) C() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C()
+}
+
+func (t *prefetchTimer) reset(d time.Duration) {
+	if t.timer == nil {
+		t.timer = t.clock.NewTimer(d)
+		return
+	}
+	if !t.timer.Stop() {
+		select {
+		case <-t.timer.C():
+		default:
+		}
+	}
+	t.timer.Reset(d)
+}
+
+func (t *prefetchTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// prefetch fetches tokens for the credentials concurrently, scheduling the timer for when they must be fetched again.
+func (t *prefetchTimer) prefetch(ctx context.Context, credentials ...*reloadingCredential) {
+	delays := make([]time.Duration, len(credentials))
+	enabled := make([]bool, len(credentials))
+	var wg sync.WaitGroup
+	for i, credential := range credentials {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			delays[i], enabled[i] = credential.prefetchTokens(ctx)
+		}()
+	}
+	wg.Wait()
+
+	next, scheduled := time.Duration(-1), false
+	for i := range credentials {
+		if enabled[i] {
+			next, scheduled = earliest(next, delays[i]), true
+		}
+	}
+	if scheduled && ctx.Err() == nil {
+		t.reset(next)
+	}
+}
+
+// prefetchUntilDone prefetches tokens for the credentials up-front, whenever they are reloaded, and before the
+// tokens expire, until ctx is done.
+func prefetchUntilDone(ctx context.Context, clock clock.Clock, reloaded <-chan struct{}, credentials func() []*reloadingCredential) {
+	timer := prefetchTimer{clock: clock}
+	defer timer.stop()
+	timer.prefetch(ctx, credentials()...)
+	for {
+		select {
+		case <-reloaded:
+			timer.prefetch(ctx, credentials()...)
+		case <-timer.C():
+			timer.prefetch(ctx, credentials()...)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
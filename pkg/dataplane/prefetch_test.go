@@ -0,0 +1,177 @@
+package dataplane
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/go-logr/logr"
+)
+
+// fakeEntra issues tokens from a fake Entra tenant, counting the tokens issued.
+type fakeEntra struct {
+	lock      sync.Mutex
+	issued    int
+	expiresIn int
+	// hang, when set, holds token requests until they are cancelled
+	hang bool
+}
+
+func (f *fakeEntra) Do(req *http.Request) (*http.Response, error) {
+	var body string
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/.well-known/openid-configuration"):
+		tenant := strings.Split(strings.Trim(req.URL.Path, "/"), "/")[0]
+		authority := fmt.Sprintf("https://%s/%s", req.URL.Host, tenant)
+		body = fmt.Sprintf(`{"token_endpoint":"%[1]s/oauth2/v2.0/token","authorization_endpoint":"%[1]s/oauth2/v2.0/authorize","issuer":"%[1]s/v2.0"}`, authority)
+	case strings.HasSuffix(req.URL.Path, "/oauth2/v2.0/token"):
+		if f.hang {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		f.lock.Lock()
+		f.issued++
+		body = fmt.Sprintf(`{"access_token":"token-%d","expires_in":%d,"token_type":"Bearer"}`, f.issued, f.expiresIn)
+		f.lock.Unlock()
+	default:
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: req}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeEntra) issuedCount() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.issued
+}
+
+func TestTokenPrefetch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	logger := logr.Discard()
+	entra := &fakeEntra{expiresIn: 3600}
+	path := filepath.Join(t.TempDir(), "credential.json")
+	writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, now.Add(-time.Hour)))
+
+	tokenCredential, err := NewUserAssignedIdentityCredential(ctx, path,
+		WithLogger(&logger),
+		WithClientOpts(azcore.ClientOptions{Transport: entra}),
+		WithTokenPrefetch(5*time.Minute, "https://management.azure.com/.default"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	credential := tokenCredential.(*reloadingCredential)
+	defer func() {
+		if err := credential.Close(); err != nil {
+			t.Errorf("failed to close credential: %v", err)
+		}
+	}()
+
+	prefetched := func() (azcore.AccessToken, bool) {
+		return credential.prefetch.cached(time.Now(), credential.current(), policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}})
+	}
+
+	t.Log("tokens are fetched in the background once credentials are loaded")
+	waitFor(t, func() bool { _, ok := prefetched(); return ok })
+	token, err := credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}})
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+	if issued := entra.issuedCount(); issued != 1 || token.Token != "token-1" {
+		t.Errorf("expected the prefetched token to be served, got %q after %d tokens were issued", token.Token, issued)
+	}
+
+	t.Log("tokens are fetched again once credentials are reloaded")
+	writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, now))
+	waitFor(t, func() bool { token, ok := prefetched(); return ok && token.Token == "token-2" })
+
+	t.Log("requests for other scopes are passed through")
+	token, err = credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://vault.azure.net/.default"}})
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+	if token.Token != "token-3" {
+		t.Errorf("expected a token to be issued for another scope, got %q", token.Token)
+	}
+}
+
+func TestTokenPrefetchBeforeExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := logr.Discard()
+	// tokens live for two seconds past the refresh window, so they're fetched again every two seconds
+	entra := &fakeEntra{expiresIn: 302}
+	path := filepath.Join(t.TempDir(), "credential.json")
+	writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, time.Now().Add(-time.Hour)))
+
+	tokenCredential, err := NewUserAssignedIdentityCredential(ctx, path,
+		WithLogger(&logger),
+		WithClientOpts(azcore.ClientOptions{Transport: entra}),
+		WithTokenPrefetch(5*time.Minute, "https://management.azure.com/.default"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	defer func() {
+		if err := tokenCredential.(*reloadingCredential).Close(); err != nil {
+			t.Errorf("failed to close credential: %v", err)
+		}
+	}()
+
+	waitFor(t, func() bool { return entra.issuedCount() >= 3 })
+}
+
+func TestTokenPrefetchDoesNotHoldUpReloads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	logger := logr.Discard()
+	entra := &fakeEntra{expiresIn: 3600, hang: true}
+	path := filepath.Join(t.TempDir(), "credential.json")
+	writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, now.Add(-time.Hour)))
+
+	tokenCredential, err := NewUserAssignedIdentityCredential(ctx, path,
+		WithLogger(&logger),
+		WithClientOpts(azcore.ClientOptions{Transport: entra}),
+		WithTokenPrefetch(5*time.Minute, "https://management.azure.com/.default"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	credential := tokenCredential.(*reloadingCredential)
+
+	t.Log("credentials are reloaded while a prefetch hangs")
+	initial := credential.current()
+	writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, now))
+	waitFor(t, func() bool { return credential.current() != initial })
+
+	t.Log("closing the credential cancels the prefetch")
+	closed := make(chan error)
+	go func() { closed <- credential.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("failed to close credential: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the credential to close")
+	}
+}
@@ -0,0 +1,311 @@
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrIdentityNotInDirectory is returned when selecting an identity that is not held by any file in the credential directory.
+var ErrIdentityNotInDirectory = errors.New("identity not found in credential directory")
+
+// CredentialDirectory exposes a reloading credential for each file in a directory holding UserAssignedIdentityCredentials,
+// one identity per file, as when the credentials for each identity are mounted from their own secret. Files are looked up
+// every time a token is requested, so a credential selected from the directory follows the identity as files are added,
+// replaced and removed.
+type CredentialDirectory interface {
+	// ForFile selects the identity held in the file with the name, relative to the directory.
+	ForFile(name string) (azcore.TokenCredential, error)
+	// ForClientID selects the identity with the client ID.
+	ForClientID(clientID string) (azcore.TokenCredential, error)
+	// ForResourceID selects the identity with the ARM resource ID.
+	ForResourceID(resourceID string) (azcore.TokenCredential, error)
+	// Close stops watching the directory and waits for the background process to exit.
+	// Credentials selected from the directory continue to serve the last certificates loaded.
+	io.Closer
+}
+
+type credentialDirectory struct {
+	// template holds the options used to create the credential for each file
+	template *reloadingCredential
+	dir      string
+
+	lock  sync.RWMutex
+	files map[string]*directoryEntry
+}
+
+var _ CredentialDirectory = (*credentialDirectory)(nil)
+
+// directoryEntry is the identity held in one file of the directory, along with the content last loaded from it.
+// The credential for the identity is only built once the identity is selected; until then, the entry holds the
+// credentials decoded from the file to build it from.
+type directoryEntry struct {
+	identity *identityCredential
+	raw      []byte
+	pending  *UserAssignedIdentityCredentials
+}
+
+// NewCredentialDirectory creates a new CredentialDirectory for the files in a directory.
+// ctx is used to manage the lifecycle of the reloader, allowing for cancellation if reloading is no longer needed.
+// dir is the path to the directory. Files with the .json extension are loaded, except for hidden files.
+// opts allows for additional configuration, such as setting a custom logger, periodic reload time, and cloud environment.
+//
+// The function fails when the directory cannot be read. Files are decoded as they are found, to tell which identity
+// each holds, but the credential for an identity is only built when it is first selected, which fails if the
+// credentials in its file are not valid. Files that fail to load are logged and loaded again as they change, without
+// affecting the other files. It also starts a background process that watches the directory with one watcher,
+// loading files as they are added or changed and forgetting them when they are removed.
+func NewCredentialDirectory(ctx context.Context, dir string, opts ...Option) (CredentialDirectory, error) {
+	template, err := newReloadingCredential(6*time.Hour, opts...)
+	if err != nil {
+		return nil, err
+	}
+	directory := &credentialDirectory{
+		template: template,
+		dir:      dir,
+		files:    map[string]*directoryEntry{},
+	}
+
+	if _, err := os.ReadDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to read credential directory %s: %w", dir, err)
+	}
+	// load once before we return, so identities are ready to be selected
+	if err := directory.load(); err != nil {
+		template.logger.Error(err, "failed to load credential files")
+	}
+	// start the process of watching - the caller can cancel ctx or close the directory if they want to stop
+	if err := directory.start(ctx); err != nil {
+		return nil, err
+	}
+	return directory, nil
+}
+
+func (d *credentialDirectory) ForFile(name string) (azcore.TokenCredential, error) {
+	return selectIdentity(d, ErrIdentityNotInDirectory, "file", name, func(identity *identityCredential) string { return identity.file })
+}
+
+func (d *credentialDirectory) ForClientID(clientID string) (azcore.TokenCredential, error) {
+	return selectIdentity(d, ErrIdentityNotInDirectory, "client ID", clientID, func(identity *identityCredential) string { return identity.clientID })
+}
+
+func (d *credentialDirectory) ForResourceID(resourceID string) (azcore.TokenCredential, error) {
+	return selectIdentity(d, ErrIdentityNotInDirectory, "resource ID", resourceID, func(identity *identityCredential) string { return identity.resourceID })
+}
+
+func (d *credentialDirectory) Close() error {
+	return d.template.Close()
+}
+
+func (d *credentialDirectory) defaults() *reloadingCredential {
+	return d.template
+}
+
+func (d *credentialDirectory) find(matches func(*identityCredential) bool) (*reloadingCredential, error) {
+	d.lock.RLock()
+	var found *directoryEntry
+	for _, entry := range d.files {
+		if matches(entry.identity) {
+			found = entry
+			break
+		}
+	}
+	var credential *reloadingCredential
+	var pending *UserAssignedIdentityCredentials
+	if found != nil {
+		credential, pending = found.identity.credential, found.pending
+	}
+	d.lock.RUnlock()
+	if credential != nil || pending == nil {
+		return credential, nil
+	}
+	return d.build(found, *pending)
+}
+
+// build creates the credential for the identity in the entry from the credentials decoded from its file, the first
+// time the identity is selected. The lock is not held while loading the credentials, as subscribers to changes may
+// call back into the directory.
+func (d *credentialDirectory) build(entry *directoryEntry, pending UserAssignedIdentityCredentials) (*reloadingCredential, error) {
+	credential := d.template.forIdentity()
+	if err := credential.update(pending); err != nil {
+		return nil, fmt.Errorf("failed to load credential file %s: %w", filepath.Join(d.dir, entry.identity.file), err)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// the identity may have been selected, and its credential built, by another caller in the meantime
+	if entry.identity.credential == nil {
+		entry.identity.credential = credential
+		entry.pending = nil
+	}
+	return entry.identity.credential, nil
+}
+
+// credentials lists the credential for every file holding an identity that was selected.
+func (d *credentialDirectory) credentials() []*reloadingCredential {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	credentials := make([]*reloadingCredential, 0, len(d.files))
+	for _, entry := range d.files {
+		if entry.identity.credential != nil {
+			credentials = append(credentials, entry.identity.credential)
+		}
+	}
+	return credentials
+}
+
+func (d *credentialDirectory) start(ctx context.Context) error {
+	// set up the directory watcher, call load() when we see events or on some timer in case no events are delivered
+	dirWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	// we close the watcher if adding the directory to watch fails.
+	if err := dirWatcher.Add(d.dir); err != nil {
+		if closeErr := dirWatcher.Close(); closeErr != nil {
+			d.template.logger.Error(closeErr, "failed to close file watcher")
+		}
+		return fmt.Errorf("failed to add credential directory to file watcher: %w", err)
+	}
+	d.template.run(ctx, reloader{
+		watcher: dirWatcher,
+		onEvent: d.loadEvent,
+		reload: func(context.Context) error {
+			return d.load()
+		},
+		credentials: d.credentials,
+	})
+	return nil
+}
+
+// loadEvent loads the credential file named in the event. Volumes mounted from Kubernetes secrets swap every file
+// at once by replacing the ..data symlink, so events for entries starting with ".." rescan the directory instead.
+// Events for other files are ignored.
+func (d *credentialDirectory) loadEvent(event fsnotify.Event) (err error) {
+	name := filepath.Base(event.Name)
+	if strings.HasPrefix(name, "..") {
+		return d.load()
+	}
+	if !isCredentialFile(name) {
+		return nil
+	}
+	defer func() { d.template.health.record(err) }()
+	return d.loadFile(name)
+}
+
+// isCredentialFile determines if the file with the name holds credentials.
+func isCredentialFile(name string) bool {
+	return !strings.HasPrefix(name, ".") && filepath.Ext(name) == ".json"
+}
+
+// load scans the directory, loading files that are new or have changed and forgetting files that were removed.
+// Files that fail to load keep the identity last loaded from them, if any, and do not affect the other files.
+func (d *credentialDirectory) load() (err error) {
+	defer func() { d.template.health.record(err) }()
+
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read credential directory %s: %w", d.dir, err)
+	}
+
+	var errs []error
+	present := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !isCredentialFile(name) {
+			continue
+		}
+		present[name] = true
+		if err := d.loadFile(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	d.lock.Lock()
+	for name := range d.files {
+		if !present[name] {
+			delete(d.files, name)
+		}
+	}
+	d.lock.Unlock()
+	return errors.Join(errs...)
+}
+
+// loadFile loads one file from the directory, forgetting it when it is gone or is not a regular file. The credential
+// built for the file before is reused while the file holds the same identity, and the entry for the file is only
+// replaced once the credentials in it are loaded, so a file that fails to load keeps the identity last loaded from it.
+// Until the identity in the file is selected, the credentials are only decoded.
+func (d *credentialDirectory) loadFile(name string) error {
+	d.lock.RLock()
+	existing := d.files[name]
+	var existingCredential *reloadingCredential
+	if existing != nil {
+		existingCredential = existing.identity.credential
+	}
+	d.lock.RUnlock()
+
+	path := filepath.Join(d.dir, name)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		d.forget(name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat credential file %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		d.forget(name)
+		return nil
+	}
+
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read credential file %s: %w", path, err)
+	}
+	if existing != nil && bytes.Equal(byteValue, existing.raw) {
+		return nil
+	}
+
+	var credentials UserAssignedIdentityCredentials
+	if err := d.template.decodeCredentials(byteValue, &credentials); err != nil {
+		return fmt.Errorf("failed to decode credential file %s: %w", path, err)
+	}
+
+	identity := &identityCredential{
+		file:       name,
+		clientID:   strings.ToLower(stringOrEmpty(credentials.ClientID)),
+		objectID:   strings.ToLower(stringOrEmpty(credentials.ObjectID)),
+		resourceID: strings.ToLower(stringOrEmpty(credentials.ResourceID)),
+	}
+	entry := &directoryEntry{identity: identity, raw: byteValue}
+	// a file that now holds another identity must not hand that identity to callers selecting the old one
+	if existingCredential != nil && existing.identity.sameIdentity(identity) {
+		identity.credential = existingCredential
+		if err := identity.credential.update(credentials); err != nil {
+			return fmt.Errorf("failed to load credential file %s: %w", path, err)
+		}
+	} else {
+		entry.pending = &credentials
+	}
+
+	d.lock.Lock()
+	d.files[name] = entry
+	d.lock.Unlock()
+	return nil
+}
+
+func (d *credentialDirectory) forget(name string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.files, name)
+}
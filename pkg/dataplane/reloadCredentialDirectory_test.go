@@ -0,0 +1,241 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+func TestCredentialDirectory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	dir := t.TempDir()
+	identity := testIdentityCredentials
+	writeCredentialsFile(t, filepath.Join(dir, "first.json"), identity(t, "first", now.Add(-2*time.Hour)))
+	writeCredentialsFile(t, filepath.Join(dir, "second.json"), identity(t, "second", now.Add(-2*time.Hour)))
+	// hidden files and files without the extension are not credentials
+	if err := os.WriteFile(filepath.Join(dir, ".first.json.tmp"), []byte("{"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("hello"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	// a broken file does not keep the others from loading
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	logger := logr.Discard()
+	created, err := NewCredentialDirectory(ctx, dir, WithLogger(&logger))
+	if err != nil {
+		t.Fatalf("failed to create credential directory: %v", err)
+	}
+	defer func() {
+		if err := created.Close(); err != nil {
+			t.Errorf("failed to close credential directory: %v", err)
+		}
+	}()
+	directory := created.(*credentialDirectory)
+
+	resolved := func(t *testing.T, selected azcore.TokenCredential) *reloadingCredential {
+		t.Helper()
+		credential, err := selected.(*selectedIdentityCredential).resolve()
+		if err != nil {
+			t.Fatalf("failed to resolve credential: %v", err)
+		}
+		return credential
+	}
+
+	for name, testCase := range map[string]struct {
+		selector func() (azcore.TokenCredential, error)
+		file     string
+	}{
+		"by file":      {selector: func() (azcore.TokenCredential, error) { return directory.ForFile("first.json") }, file: "first.json"},
+		"by client ID": {selector: func() (azcore.TokenCredential, error) { return directory.ForClientID("SECOND-CLIENT") }, file: "second.json"},
+		"by resource ID": {selector: func() (azcore.TokenCredential, error) {
+			return directory.ForResourceID("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/first")
+		}, file: "first.json"},
+	} {
+		selected, err := testCase.selector()
+		if err != nil {
+			t.Fatalf("%s: failed to select identity: %v", name, err)
+		}
+		directory.lock.RLock()
+		expected := directory.files[testCase.file].identity.credential
+		directory.lock.RUnlock()
+		if resolved(t, selected) != expected {
+			t.Errorf("%s: selected the wrong identity", name)
+		}
+	}
+	if _, err := directory.ForFile(".first.json.tmp"); !errors.Is(err, ErrIdentityNotInDirectory) {
+		t.Errorf("expected hidden files to be ignored, got %v", err)
+	}
+	if _, err := directory.ForFile("broken.json"); !errors.Is(err, ErrIdentityNotInDirectory) {
+		t.Errorf("expected broken files not to be loaded, got %v", err)
+	}
+
+	t.Log("broken files are loaded once they are fixed")
+	writeCredentialsFile(t, filepath.Join(dir, "broken.json"), identity(t, "fixed", now.Add(-2*time.Hour)))
+	waitFor(t, func() bool {
+		_, err := directory.ForClientID("fixed-client")
+		return err == nil
+	})
+
+	t.Log("files added to the directory are loaded")
+	writeCredentialsFile(t, filepath.Join(dir, "third.json"), identity(t, "third", now.Add(-2*time.Hour)))
+	waitFor(t, func() bool {
+		_, err := directory.ForClientID("third-client")
+		return err == nil
+	})
+
+	t.Log("files changed in the directory are reloaded")
+	first, err := directory.ForFile("first.json")
+	if err != nil {
+		t.Fatalf("failed to select identity: %v", err)
+	}
+	initial := resolved(t, first).current()
+	writeCredentialsFile(t, filepath.Join(dir, "first.json"), identity(t, "first", now.Add(-time.Hour)))
+	waitFor(t, func() bool { return resolved(t, first).current() != initial })
+
+	t.Log("files that hold another identity get a credential of their own")
+	fixed, err := directory.ForFile("broken.json")
+	if err != nil {
+		t.Fatalf("failed to select identity: %v", err)
+	}
+	previous := resolved(t, fixed)
+	writeCredentialsFile(t, filepath.Join(dir, "broken.json"), identity(t, "replaced", now.Add(-time.Hour)))
+	waitFor(t, func() bool {
+		_, err := directory.ForClientID("replaced-client")
+		return err == nil
+	})
+	if _, err := directory.ForClientID("fixed-client"); !errors.Is(err, ErrIdentityNotInDirectory) {
+		t.Errorf("expected the identity no longer in the file to be gone, got %v", err)
+	}
+	if resolved(t, fixed) == previous {
+		t.Errorf("expected the new identity to get a new credential")
+	}
+	if previous.Status().Current.ClientID != "fixed-client" {
+		t.Errorf("expected the credential for the old identity to be left alone, got %s", previous.Status().Current.ClientID)
+	}
+
+	t.Log("files removed from the directory can no longer be used")
+	second, err := directory.ForFile("second.json")
+	if err != nil {
+		t.Fatalf("failed to select identity: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "second.json")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	waitFor(t, func() bool {
+		_, err := second.GetToken(ctx, policy.TokenRequestOptions{})
+		return errors.Is(err, ErrIdentityNotInDirectory)
+	})
+}
+
+func TestCredentialDirectoryLoadsFileFromEvent(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	writeCredentialsFile(t, filepath.Join(dir, "first.json"), testIdentityCredentials(t, "first", now.Add(-2*time.Hour)))
+	writeCredentialsFile(t, filepath.Join(dir, "second.json"), testIdentityCredentials(t, "second", now.Add(-2*time.Hour)))
+
+	logger := logr.Discard()
+	created, err := NewCredentialDirectory(context.Background(), dir, WithLogger(&logger))
+	if err != nil {
+		t.Fatalf("failed to create credential directory: %v", err)
+	}
+	// stop watching, so the test decides which events are seen
+	if err := created.Close(); err != nil {
+		t.Fatalf("failed to close credential directory: %v", err)
+	}
+	directory := created.(*credentialDirectory)
+
+	writeCredentialsFile(t, filepath.Join(dir, "first.json"), testIdentityCredentials(t, "first", now.Add(-time.Hour)))
+	if err := os.WriteFile(filepath.Join(dir, "second.json"), []byte("{"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := directory.loadEvent(fsnotify.Event{Name: filepath.Join(dir, "first.json"), Op: fsnotify.Write}); err != nil {
+		t.Errorf("expected only the file in the event to be loaded, got %v", err)
+	}
+	first, err := directory.ForFile("first.json")
+	if err != nil {
+		t.Fatalf("failed to select identity: %v", err)
+	}
+	if notBefore := first.(CredentialStatusReporter).Status().Current.NotBefore; !notBefore.After(now.Add(-2 * time.Hour)) {
+		t.Errorf("expected the file in the event to be reloaded, got a certificate from %s", notBefore)
+	}
+
+	if err := directory.loadEvent(fsnotify.Event{Name: filepath.Join(dir, "..data"), Op: fsnotify.Create}); err == nil {
+		t.Errorf("expected a swap of the data symlink to rescan the directory")
+	}
+	if _, err := directory.ForFile("second.json"); err != nil {
+		t.Errorf("expected a broken file to keep the identity last loaded from it, got %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "first.json")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if err := directory.loadEvent(fsnotify.Event{Name: filepath.Join(dir, "first.json"), Op: fsnotify.Remove}); err != nil {
+		t.Errorf("failed to forget a removed file: %v", err)
+	}
+	if _, err := directory.ForFile("first.json"); !errors.Is(err, ErrIdentityNotInDirectory) {
+		t.Errorf("expected a removed file to be forgotten, got %v", err)
+	}
+}
+
+func TestCredentialDirectoryBuildsCredentialsWhenSelected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	dir := t.TempDir()
+	writeCredentialsFile(t, filepath.Join(dir, "first.json"), testIdentityCredentials(t, "first", now.Add(-2*time.Hour)))
+	writeCredentialsFile(t, filepath.Join(dir, "expired.json"), testIdentityCredentials(t, "expired", now.Add(-100*24*time.Hour)))
+
+	logger := logr.Discard()
+	created, err := NewCredentialDirectory(ctx, dir, WithLogger(&logger))
+	if err != nil {
+		t.Fatalf("failed to create credential directory: %v", err)
+	}
+	defer func() {
+		if err := created.Close(); err != nil {
+			t.Errorf("failed to close credential directory: %v", err)
+		}
+	}()
+	directory := created.(*credentialDirectory)
+
+	if credentials := directory.credentials(); len(credentials) != 0 {
+		t.Errorf("expected no credential to be built before an identity is selected, got %d", len(credentials))
+	}
+
+	first, err := directory.ForClientID("first-client")
+	if err != nil {
+		t.Fatalf("failed to select identity: %v", err)
+	}
+	credential, err := first.(*selectedIdentityCredential).resolve()
+	if err != nil {
+		t.Fatalf("failed to resolve credential: %v", err)
+	}
+	if credentials := directory.credentials(); len(credentials) != 1 || credentials[0] != credential {
+		t.Errorf("expected only the credential for the selected identity to be built, got %d", len(credentials))
+	}
+	if again, err := directory.ForFile("first.json"); err != nil {
+		t.Errorf("failed to select identity again: %v", err)
+	} else if resolved, err := again.(*selectedIdentityCredential).resolve(); err != nil || resolved != credential {
+		t.Errorf("expected selecting the identity again to reuse its credential, got %v", err)
+	}
+
+	if _, err := directory.ForFile("expired.json"); !errors.Is(err, ErrCredentialExpired) {
+		t.Errorf("expected selecting an identity with expired credentials to fail, got %v", err)
+	}
+}
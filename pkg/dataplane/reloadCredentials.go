@@ -2,9 +2,9 @@ package dataplane
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
+	"io"
 	"os"
 	"sync"
 	"time"
@@ -14,17 +14,48 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-logr/logr"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
 )
 
+// ErrCredentialRolledBack is returned when loading credentials that are older than the ones held, which are kept.
+// This may indicate that the source of the credentials was rolled back.
+var ErrCredentialRolledBack = errors.New("loaded credential is older than the one held")
+
 type reloadingCredential struct {
 	clientOpts   azcore.ClientOptions
 	currentValue *azidentity.ClientCertificateCredential
 	notBefore    string
 	lock         *sync.RWMutex
 	logger       *logr.Logger
-	ticker       *time.Ticker
+	// backstop is the interval at which credentials are reloaded even if no change is seen, and ticker
+	// ticks at that interval once reloading starts
+	backstop time.Duration
+	ticker   clock.Ticker
+	clock    clock.Clock
+	// skewTolerance is how far the clock may drift when judging whether credentials are valid
+	skewTolerance time.Duration
+	// decryptionKeys, when set, decrypt credential files that are encrypted at rest
+	decryptionKeys []CredentialEncryptionKey
+	// optionErr holds the errors from applying invalid options, which fail the constructor
+	optionErr error
+	// cloud, when set, limits the authentication endpoints that loaded credentials may use
+	cloud *CloudConfiguration
+	// metadata describes the certificate in currentValue
+	metadata CredentialMetadata
+	// health records the outcome of loading credentials
+	health *loadHealth
+	// onChange, when set, is notified when credentials are swapped or rejected as older
+	onChange func(CredentialChange)
+	// prefetch, when set, holds tokens fetched ahead of time
+	prefetch *tokenPrefetch
+	// cancel stops the goroutine that reloads credentials, which closes done when it exits
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
+var _ io.Closer = (*reloadingCredential)(nil)
+
 type Option func(*reloadingCredential)
 
 // WithLogger sets a custom logger for the reloadingCredential.
@@ -39,7 +70,15 @@ func WithLogger(logger *logr.Logger) Option {
 // This can be useful for loading credential file periodically.
 func WithBackstopRefresh(d time.Duration) Option {
 	return func(c *reloadingCredential) {
-		c.ticker = time.NewTicker(d)
+		c.backstop = d
+	}
+}
+
+// WithClock sets the clock used to time reloads and to judge the freshness of credentials and tokens,
+// for instance to a clock.Fake in tests.
+func WithClock(clock clock.Clock) Option {
+	return func(c *reloadingCredential) {
+		c.clock = clock
 	}
 }
 
@@ -58,23 +97,18 @@ func WithClientOpts(o azcore.ClientOptions) Option {
 //
 // The function ensures that a valid token is loaded before returning the credential.
 // It also starts a background process to watch for changes to the credential file and reloads it as necessary.
+// The credential implements io.Closer: Close stops the background process and waits for it to exit.
 func NewUserAssignedIdentityCredential(ctx context.Context, credentialPath string, opts ...Option) (azcore.TokenCredential, error) {
-	defaultLog := logr.FromSlogHandler(slog.NewTextHandler(os.Stdout, nil))
-	credential := &reloadingCredential{
-		lock:   &sync.RWMutex{},
-		logger: &defaultLog,
-		ticker: time.NewTicker(6 * time.Hour),
-	}
-
-	for _, opt := range opts {
-		opt(credential)
+	credential, err := newReloadingCredential(6*time.Hour, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	// load once to validate everything and ensure we have a useful token before we return
 	if err := credential.load(credentialPath); err != nil {
 		return nil, err
 	}
-	// start the process of watching - the caller can cancel ctx if they want to stop
+	// start the process of watching - the caller can cancel ctx or close the credential if they want to stop
 	if err := credential.start(ctx, credentialPath); err != nil {
 		return nil, err
 	}
@@ -82,102 +116,164 @@ func NewUserAssignedIdentityCredential(ctx context.Context, credentialPath strin
 }
 
 // GetToken retrieves the current token from the reloadingCredential.
-// It uses a read lock to ensure that the token is not being modified while it is being read.
+// It uses a read lock to ensure that the credential is not being modified while it is being read.
 // options specifies additional options for the token request.
 func (r *reloadingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
 	r.lock.RLock()
-	defer r.lock.RUnlock()
-	return r.currentValue.GetToken(ctx, options)
+	current := r.currentValue
+	r.lock.RUnlock()
+	// fetching a token may take a round-trip to Entra, which must not hold up a reload
+	if token, ok := r.prefetch.cached(r.clock.Now(), current, options); ok {
+		return token, nil
+	}
+	return current.GetToken(ctx, options)
 }
 
 func (r *reloadingCredential) start(ctx context.Context, credentialFile string) error {
 	// set up the file watcher, call load() when we see events or on some timer in case no events are delivered
-	fileWatcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("failed to create file watcher: %w", err)
-	}
-	// we close the file watcher if adding the file to watch fails.
-	// this will also close the new go routine created to watch the file
-	err = fileWatcher.Add(credentialFile)
+	fileWatcher, err := newCredentialFileWatcher(credentialFile, r.logger)
 	if err != nil {
-		if closeErr := fileWatcher.Close(); closeErr != nil {
-			r.logger.Error(err, "failed to close file watcher")
-		}
-		return fmt.Errorf("failed to add credential file to file watcher: %w", err)
+		return err
 	}
-
-	go func() {
-		defer func() {
-			if err := fileWatcher.Close(); err != nil {
-				r.logger.Error(err, "failed to close file watcher")
-			}
-		}()
-		defer r.ticker.Stop()
-		for {
-			select {
-			case event, ok := <-fileWatcher.Events:
-				if !ok {
-					r.logger.Info("stopping credential reloader since file watcher has no events")
-					return
-				}
-				if event.Op.Has(fsnotify.Write) {
-					if err := r.load(credentialFile); err != nil {
-						r.logger.Error(err, "failed to reload credential after file event")
-					}
-				}
-			case <-r.ticker.C:
-				if err := r.load(credentialFile); err != nil {
-					r.logger.Error(err, "failed to reload credential periodically")
-				}
-			case err, ok := <-fileWatcher.Errors:
-				if !ok {
-					r.logger.Info("stopping credential reloader since file watcher has no events")
-					return
-				}
-				r.logger.Error(err, "recieved an error from the file watcher")
-			case <-ctx.Done():
-				r.logger.Info("user signaled context cancel, stopping credential reloader")
-				return
+	r.run(ctx, reloader{
+		watcher: fileWatcher.Watcher,
+		onEvent: func(event fsnotify.Event) error {
+			if !fileWatcher.shouldReload(event) {
+				return nil
 			}
-		}
-	}()
+			return r.load(credentialFile)
+		},
+		reload: func(context.Context) error {
+			return r.load(credentialFile)
+		},
+		credentials: func() []*reloadingCredential {
+			return []*reloadingCredential{r}
+		},
+	})
 	return nil
 }
 
-func (r *reloadingCredential) load(credentialFile string) error {
-	// read the file from the filesystem and update the current value we're holding on to if the certificate we read is newer, making sure to not step on the toes of anyone calling GetToken()
+// Close stops reloading credentials and waits for the background process to exit, so no load is in flight
+// once it returns. The credential continues to serve the last certificate it loaded. Close must not be
+// called from a function registered with WithChangeNotification.
+func (r *reloadingCredential) Close() error {
+	if r.cancel == nil {
+		// credentials for identities in a ManagedIdentityCredentialSet are reloaded by the set
+		return nil
+	}
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+func (r *reloadingCredential) load(credentialFile string) (err error) {
+	defer func() { r.health.record(err) }()
+
+	// read the file from the filesystem
 	byteValue, err := os.ReadFile(credentialFile)
 	if err != nil {
 		return fmt.Errorf("failed to read credential file %s: %w", credentialFile, err)
 	}
 
 	var credentials UserAssignedIdentityCredentials
-	if err := json.Unmarshal(byteValue, &credentials); err != nil {
-		return fmt.Errorf("failed to unmarshal credential file %s: %w", credentialFile, err)
+	if err := r.decodeCredentials(byteValue, &credentials); err != nil {
+		return fmt.Errorf("failed to decode credential file %s: %w", credentialFile, err)
 	}
 
-	var newCertValue *azidentity.ClientCertificateCredential
-	newCertValue, err = GetCredential(r.clientOpts, credentials)
+	return r.update(credentials)
+}
+
+// CredentialChange describes a change to the certificate held by a reloading credential.
+type CredentialChange struct {
+	// Previous describes the certificate that was held before the change, or is nil when loading the first certificate.
+	Previous *CredentialMetadata
+	// Current describes the certificate that was loaded.
+	Current CredentialMetadata
+	// Rejected is true when the loaded certificate was older than the one held, which was kept. This may indicate that
+	// the source of the credentials was rolled back.
+	Rejected bool
+}
+
+// WithChangeNotification registers a function to call whenever the reloading credential swaps the certificate it holds,
+// including when the first certificate is loaded, or rejects a loaded certificate as older than the one it holds.
+// Identities in a ManagedIdentityCredentialSet each notify the function as their certificates change. The function is
+// called synchronously from the goroutine that loads credentials, so it should return quickly.
+func WithChangeNotification(notify func(CredentialChange)) Option {
+	return func(c *reloadingCredential) {
+		c.onChange = notify
+	}
+}
+
+// update loads the credentials, failing with ErrCredentialRolledBack when they are older than the ones held.
+func (r *reloadingCredential) update(credentials UserAssignedIdentityCredentials) error {
+	change, err := r.swap(credentials)
 	if err != nil {
-		return fmt.Errorf("failed to get client certificate credential: %w", err)
+		return err
+	}
+	if change == nil {
+		return nil
+	}
+	// notify outside the lock, so subscribers may call back into the credential
+	if r.onChange != nil {
+		r.onChange(*change)
+	}
+	if change.Rejected {
+		return fmt.Errorf("%w: not_before %s is before %s", ErrCredentialRolledBack,
+			change.Current.NotBefore.Format(time.RFC3339), change.Previous.NotBefore.Format(time.RFC3339))
+	}
+	r.health.swapped(r.clock.Now())
+	return nil
+}
+
+// swap replaces the current value with the credentials if they are newer, returning the change to notify subscribers of, if any.
+func (r *reloadingCredential) swap(credentials UserAssignedIdentityCredentials) (*CredentialChange, error) {
+	if r.cloud != nil {
+		if credentials.AuthenticationEndpoint == nil {
+			return nil, fmt.Errorf("credential is not valid for cloud %s: %w: authenticationEndpoint", r.cloud.Name, errNilField)
+		}
+		if err := r.cloud.ValidateAuthority(*credentials.AuthenticationEndpoint); err != nil {
+			return nil, fmt.Errorf("credential is not valid for cloud %s: %w", r.cloud.Name, err)
+		}
+	}
+
+	metadata, err := credentialMetadata(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.validateCredentials(credentials, metadata); err != nil {
+		return nil, err
+	}
+
+	// update the current value we're holding on to if the certificate we were given is newer, making sure to not step on the toes of anyone calling GetToken()
+	newCertValue, err := GetCredential(r.clientOpts, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client certificate credential: %w", err)
 	}
 
 	r.lock.Lock()
 	defer r.lock.Unlock()
+	var previous *CredentialMetadata
 	if r.notBefore != "" {
 		err, ok := isLoadedCredentialNewer(*credentials.NotBefore, r.notBefore)
 		if err != nil {
-			return fmt.Errorf("failed to determine not_before for credential: %w", err)
+			return nil, fmt.Errorf("failed to determine not_before for credential: %w", err)
 		}
+		current := r.metadata
 		if !ok {
-			return nil
+			// reloading the certificate we already hold is not a change, but an older one is a rollback
+			if metadata.NotBefore.Before(current.NotBefore) {
+				return &CredentialChange{Previous: &current, Current: metadata, Rejected: true}, nil
+			}
+			return nil, nil
 		}
+		previous = &current
 	}
 
 	r.currentValue = newCertValue
 	r.notBefore = *credentials.NotBefore
+	r.metadata = metadata
 
-	return nil
+	return &CredentialChange{Previous: previous, Current: metadata}, nil
 }
 
 func isLoadedCredentialNewer(newCred string, currentCred string) (error, bool) {
@@ -0,0 +1,344 @@
+package dataplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/internal/selfsigned"
+)
+
+// testUserAssignedIdentityCredentials mints credentials for an identity named test, valid from notBefore.
+func testUserAssignedIdentityCredentials(t *testing.T, notBefore time.Time) UserAssignedIdentityCredentials {
+	t.Helper()
+	return testIdentityCredentials(t, "test", notBefore)
+}
+
+// testIdentityCredentials mints credentials backed by a real certificate, valid from notBefore, for the identity with
+// the name, which its client ID, object ID and resource ID are derived from.
+func testIdentityCredentials(t *testing.T, name string, notBefore time.Time) UserAssignedIdentityCredentials {
+	t.Helper()
+	notBefore = notBefore.UTC().Truncate(time.Second)
+	notAfter := notBefore.Add(90 * 24 * time.Hour)
+	clientSecret, err := selfsigned.NewClientSecret(name, notBefore, notAfter)
+	if err != nil {
+		t.Fatalf("failed to mint client secret: %v", err)
+	}
+	return UserAssignedIdentityCredentials{
+		AuthenticationEndpoint: ptrTo("https://login.microsoftonline.com/"),
+		CannotRenewAfter:       ptrTo(notAfter.Format(time.RFC3339)),
+		ClientID:               ptrTo(name + "-client"),
+		ClientSecret:           ptrTo(clientSecret),
+		NotAfter:               ptrTo(notAfter.Format(time.RFC3339)),
+		NotBefore:              ptrTo(notBefore.Format(time.RFC3339)),
+		ObjectID:               ptrTo(name + "-object"),
+		RenewAfter:             ptrTo(notBefore.Add(46 * 24 * time.Hour).Format(time.RFC3339)),
+		ResourceID:             ptrTo("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/" + name),
+		TenantID:               ptrTo("TenantID"),
+	}
+}
+
+// current returns the credential currently held, for tests to determine if a reload happened.
+func (r *reloadingCredential) current() *azidentity.ClientCertificateCredential {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.currentValue
+}
+
+// waitFor polls condition until it holds or the timeout elapses.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type fakeSecretGetter struct {
+	lock   sync.Mutex
+	secret azsecrets.Secret
+	calls  int
+}
+
+func (f *fakeSecretGetter) GetSecret(_ context.Context, name string, _ string, _ *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls++
+	return azsecrets.GetSecretResponse{Secret: f.secret}, nil
+}
+
+func (f *fakeSecretGetter) set(t *testing.T, version string, credentials UserAssignedIdentityCredentials) {
+	t.Helper()
+	name, parameters, err := FormatUserAssignedIdentityCredentialsForStorage("test", credentials)
+	if err != nil {
+		t.Fatalf("failed to format credentials: %v", err)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.secret = azsecrets.Secret{
+		ID:         ptrTo(azsecrets.ID("https://vault.vault.azure.net/secrets/" + name + "/" + version)),
+		Value:      parameters.Value,
+		Attributes: parameters.SecretAttributes,
+		Tags:       parameters.Tags,
+	}
+}
+
+func (f *fakeSecretGetter) callCount() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.calls
+}
+
+func TestKeyVaultReloadingCredential(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	getter := &fakeSecretGetter{}
+	getter.set(t, "first", testUserAssignedIdentityCredentials(t, now.Add(-time.Hour)))
+
+	logger := logr.Discard()
+	var lock sync.Mutex
+	rejections := 0
+	credential, err := newKeyVaultReloadingCredential(ctx, getter, "uamsi-test", WithLogger(&logger), WithBackstopRefresh(10*time.Millisecond), WithChangeNotification(func(change CredentialChange) {
+		lock.Lock()
+		defer lock.Unlock()
+		if change.Rejected {
+			rejections++
+		}
+	}))
+	if err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	initial := credential.current()
+
+	t.Log("polling an unchanged version should not reload")
+	waitFor(t, func() bool { return getter.callCount() > 3 })
+	if credential.current() != initial {
+		t.Errorf("expected the credential not to be reloaded for an unchanged version")
+	}
+
+	t.Log("polling a new version should reload")
+	getter.set(t, "second", testUserAssignedIdentityCredentials(t, now))
+	waitFor(t, func() bool { return credential.current() != initial })
+
+	t.Log("polling an older credential should not reload")
+	renewed := credential.current()
+	getter.set(t, "third", testUserAssignedIdentityCredentials(t, now.Add(-2*time.Hour)))
+	calls := getter.callCount()
+	waitFor(t, func() bool { return getter.callCount() > calls+3 })
+	if credential.current() != renewed {
+		t.Errorf("expected the credential not to be replaced by an older one")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if rejections != 1 {
+		t.Errorf("expected the older version to be rejected once, got %d rejections", rejections)
+	}
+	if err := credential.Status().LastError; !errors.Is(err, ErrCredentialRolledBack) {
+		t.Errorf("expected the rejection to be reported while the version is unchanged, got %v", err)
+	}
+}
+
+func writeCredentialsFile(t *testing.T, path string, credentials UserAssignedIdentityCredentials) {
+	t.Helper()
+	raw, err := json.Marshal(credentials)
+	if err != nil {
+		t.Fatalf("failed to marshal credentials: %v", err)
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+}
+
+func TestFileReloadingCredential(t *testing.T) {
+	now := time.Now()
+	logger := logr.Discard()
+
+	for _, testCase := range []struct {
+		name string
+		// setup writes the initial credentials and returns the path to load them from
+		setup func(t *testing.T, dir string, credentials UserAssignedIdentityCredentials) string
+		// rotate replaces the credentials
+		rotate func(t *testing.T, dir string, generation int, credentials UserAssignedIdentityCredentials)
+	}{
+		{
+			name: "in-place writes",
+			setup: func(t *testing.T, dir string, credentials UserAssignedIdentityCredentials) string {
+				path := filepath.Join(dir, "credential.json")
+				writeCredentialsFile(t, path, credentials)
+				return path
+			},
+			rotate: func(t *testing.T, dir string, _ int, credentials UserAssignedIdentityCredentials) {
+				writeCredentialsFile(t, filepath.Join(dir, "credential.json"), credentials)
+			},
+		},
+		{
+			name: "atomic renames",
+			setup: func(t *testing.T, dir string, credentials UserAssignedIdentityCredentials) string {
+				path := filepath.Join(dir, "credential.json")
+				writeCredentialsFile(t, path, credentials)
+				return path
+			},
+			rotate: func(t *testing.T, dir string, _ int, credentials UserAssignedIdentityCredentials) {
+				temporary := filepath.Join(dir, ".credential.json.tmp")
+				writeCredentialsFile(t, temporary, credentials)
+				if err := os.Rename(temporary, filepath.Join(dir, "credential.json")); err != nil {
+					t.Fatalf("failed to rename credentials: %v", err)
+				}
+			},
+		},
+		{
+			// Kubernetes secret volumes hold files in a timestamped directory, linked through a ..data symlink that is atomically swapped
+			name: "kubernetes symlink swaps",
+			setup: func(t *testing.T, dir string, credentials UserAssignedIdentityCredentials) string {
+				if err := os.Mkdir(filepath.Join(dir, "..generation-0"), 0700); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				writeCredentialsFile(t, filepath.Join(dir, "..generation-0", "credential.json"), credentials)
+				if err := os.Symlink("..generation-0", filepath.Join(dir, "..data")); err != nil {
+					t.Fatalf("failed to create symlink: %v", err)
+				}
+				if err := os.Symlink(filepath.Join("..data", "credential.json"), filepath.Join(dir, "credential.json")); err != nil {
+					t.Fatalf("failed to create symlink: %v", err)
+				}
+				return filepath.Join(dir, "credential.json")
+			},
+			rotate: func(t *testing.T, dir string, generation int, credentials UserAssignedIdentityCredentials) {
+				current := fmt.Sprintf("..generation-%d", generation)
+				if err := os.Mkdir(filepath.Join(dir, current), 0700); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				writeCredentialsFile(t, filepath.Join(dir, current, "credential.json"), credentials)
+				if err := os.Symlink(current, filepath.Join(dir, "..data_tmp")); err != nil {
+					t.Fatalf("failed to create symlink: %v", err)
+				}
+				if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+					t.Fatalf("failed to swap symlink: %v", err)
+				}
+				if err := os.RemoveAll(filepath.Join(dir, fmt.Sprintf("..generation-%d", generation-1))); err != nil {
+					t.Fatalf("failed to remove previous generation: %v", err)
+				}
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dir := t.TempDir()
+			path := testCase.setup(t, dir, testUserAssignedIdentityCredentials(t, now.Add(-4*time.Hour)))
+			tokenCredential, err := NewUserAssignedIdentityCredential(ctx, path, WithLogger(&logger))
+			if err != nil {
+				t.Fatalf("failed to create credential: %v", err)
+			}
+			credential := tokenCredential.(*reloadingCredential)
+
+			// rotate more than once, to ensure that we keep watching after the first rotation
+			for generation := 1; generation <= 3; generation++ {
+				previous := credential.current()
+				testCase.rotate(t, dir, generation, testUserAssignedIdentityCredentials(t, now.Add(time.Duration(generation-4)*time.Hour)))
+				waitFor(t, func() bool { return credential.current() != previous })
+			}
+		})
+	}
+}
+
+func TestReloadingCredentialClose(t *testing.T) {
+	now := time.Now()
+	logger := logr.Discard()
+
+	for _, testCase := range []struct {
+		name string
+		// create returns a closer, a function reporting if it is still watching, and a function that triggers a load
+		create func(t *testing.T) (io.Closer, func() bool, func() int)
+	}{
+		{
+			name: "file",
+			create: func(t *testing.T) (io.Closer, func() bool, func() int) {
+				path := filepath.Join(t.TempDir(), "credential.json")
+				writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, now.Add(-time.Hour)))
+				loads := 0
+				credential, err := NewUserAssignedIdentityCredential(context.Background(), path, WithLogger(&logger), WithBackstopRefresh(10*time.Millisecond), WithChangeNotification(func(CredentialChange) { loads++ }))
+				if err != nil {
+					t.Fatalf("failed to create credential: %v", err)
+				}
+				generation := 0
+				return credential.(io.Closer), func() bool { return credential.(*reloadingCredential).Status().Watching }, func() int {
+					generation++
+					writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, now.Add(time.Duration(generation)*time.Minute)))
+					return loads
+				}
+			},
+		},
+		{
+			name: "key vault",
+			create: func(t *testing.T) (io.Closer, func() bool, func() int) {
+				getter := &fakeSecretGetter{}
+				getter.set(t, "first", testUserAssignedIdentityCredentials(t, now.Add(-time.Hour)))
+				credential, err := newKeyVaultReloadingCredential(context.Background(), getter, "uamsi-test", WithLogger(&logger), WithBackstopRefresh(10*time.Millisecond))
+				if err != nil {
+					t.Fatalf("failed to create credential: %v", err)
+				}
+				return credential, func() bool { return credential.Status().Watching }, getter.callCount
+			},
+		},
+		{
+			name: "managed identity credential set",
+			create: func(t *testing.T) (io.Closer, func() bool, func() int) {
+				path := filepath.Join(t.TempDir(), "credentials.json")
+				writeManagedIdentityCredentialsFile(t, path, testManagedIdentityCredentials(t, now.Add(-time.Hour), false))
+				loads := 0
+				set, err := NewManagedIdentityCredentialSet(context.Background(), path, WithLogger(&logger), WithBackstopRefresh(10*time.Millisecond), WithChangeNotification(func(CredentialChange) { loads++ }))
+				if err != nil {
+					t.Fatalf("failed to create credential set: %v", err)
+				}
+				generation := 0
+				return set, func() bool { return set.(*managedIdentityCredentialSet).template.health.status().Watching }, func() int {
+					generation++
+					writeManagedIdentityCredentialsFile(t, path, testManagedIdentityCredentials(t, now.Add(time.Duration(generation)*time.Minute), false))
+					return loads
+				}
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			closer, watching, loads := testCase.create(t)
+			if !watching() {
+				t.Errorf("expected the credential to be watching")
+			}
+
+			if err := closer.Close(); err != nil {
+				t.Fatalf("failed to close credential: %v", err)
+			}
+			if watching() {
+				t.Errorf("expected the credential to stop watching once closed")
+			}
+			// once closed, changes to the source must not be loaded
+			before := loads()
+			time.Sleep(100 * time.Millisecond)
+			if after := loads(); after != before {
+				t.Errorf("expected no loads after closing, got %d", after-before)
+			}
+
+			// closing again is harmless
+			if err := closer.Close(); err != nil {
+				t.Fatalf("failed to close credential again: %v", err)
+			}
+		})
+	}
+}
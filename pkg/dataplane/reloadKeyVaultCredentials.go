@@ -0,0 +1,112 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// secretGetter is the subset of *azsecrets.Client used to reload credentials.
+type secretGetter interface {
+	GetSecret(ctx context.Context, name string, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error)
+}
+
+var _ secretGetter = (*azsecrets.Client)(nil)
+
+// keyVaultSource tracks the KeyVault secret a reloadingCredential loads from.
+type keyVaultSource struct {
+	client     secretGetter
+	secretName string
+	// version is the version of the secret last loaded, used to skip versions we have seen before, and err the
+	// outcome of loading it, which is reported again for as long as the version is unchanged
+	version string
+	err     error
+}
+
+// NewUserAssignedIdentityCredentialFromKeyVault creates a new reloadingCredential for a user-assigned identity
+// whose credentials are stored in Azure KeyVault, for instance using FormatUserAssignedIdentityCredentialsForStorage.
+// ctx is used to manage the lifecycle of the reloader, allowing for cancellation if reloading is no longer needed.
+// secretName is the name of the secret, which must carry the UserAssignedIdentityCredentialsStoragePrefix.
+// opts allows for additional configuration, such as setting a custom logger, poll interval, and cloud environment.
+//
+// The function ensures that a valid token is loaded before returning the credential. It also starts a background
+// process to poll the secret, every five minutes unless WithBackstopRefresh is used, and load new versions of it.
+// A version that fails to load is not loaded again, unless it was not yet valid.
+// The credential implements io.Closer: Close stops the background process and waits for it to exit.
+//
+// KeyVault encrypts secrets at rest itself, so WithDecryptionKeys is rejected with ErrDecryptionKeysNotSupported.
+func NewUserAssignedIdentityCredentialFromKeyVault(ctx context.Context, client *azsecrets.Client, secretName string, opts ...Option) (azcore.TokenCredential, error) {
+	return newKeyVaultReloadingCredential(ctx, client, secretName, opts...)
+}
+
+func newKeyVaultReloadingCredential(ctx context.Context, client secretGetter, secretName string, opts ...Option) (*reloadingCredential, error) {
+	credential, err := newReloadingCredential(5*time.Minute, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(credential.decryptionKeys) > 0 {
+		return nil, ErrDecryptionKeysNotSupported
+	}
+
+	source := &keyVaultSource{
+		client:     client,
+		secretName: secretName,
+	}
+
+	// load once to validate everything and ensure we have a useful token before we return
+	if err := credential.loadFromKeyVault(ctx, source); err != nil {
+		return nil, err
+	}
+	// start the process of polling - the caller can cancel ctx or close the credential if they want to stop
+	credential.poll(ctx, source)
+	return credential, nil
+}
+
+func (r *reloadingCredential) poll(ctx context.Context, source *keyVaultSource) {
+	r.run(ctx, reloader{
+		reload: func(ctx context.Context) error {
+			return r.loadFromKeyVault(ctx, source)
+		},
+		credentials: func() []*reloadingCredential {
+			return []*reloadingCredential{r}
+		},
+	})
+}
+
+func (r *reloadingCredential) loadFromKeyVault(ctx context.Context, source *keyVaultSource) (err error) {
+	defer func() { r.health.record(err) }()
+
+	secret, err := source.client.GetSecret(ctx, source.secretName, "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s: %w", source.secretName, err)
+	}
+
+	var version string
+	if secret.ID != nil {
+		version = secret.ID.Version()
+	}
+	if version != "" && version == source.version {
+		return source.err
+	}
+
+	// remember the version before loading it, so that a version that is rejected is not loaded again on every poll
+	source.version = version
+	source.err = r.loadSecret(source.secretName, secret)
+	if errors.Is(source.err, ErrCredentialNotYetValid) {
+		// the same version may be valid by the next poll
+		source.version = ""
+	}
+	return source.err
+}
+
+func (r *reloadingCredential) loadSecret(name string, secret azsecrets.GetSecretResponse) error {
+	credentials, err := ParseUserAssignedIdentityCredentialsFromStorage(secret)
+	if err != nil {
+		return fmt.Errorf("failed to parse secret %s: %w", name, err)
+	}
+	return r.update(credentials)
+}
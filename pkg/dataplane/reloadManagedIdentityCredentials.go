@@ -0,0 +1,328 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrIdentityNotInCredentials is returned when selecting an identity that is not present in the credentials file.
+var ErrIdentityNotInCredentials = errors.New("identity not found in managed identity credentials")
+
+// ErrEmptySelector is returned when selecting an identity by an empty identifier, which would otherwise
+// match identities that lack the identifier, like the system-assigned identity when selecting by resource ID.
+var ErrEmptySelector = errors.New("identity selector must not be empty")
+
+// ManagedIdentityCredentialSet exposes a reloading credential for each identity held in a ManagedIdentityCredentials
+// document: the system-assigned identity, explicit user-assigned identities and the identities of delegated resources.
+// Identities are looked up every time a token is requested, so a credential selected from the set follows the identity
+// as the document is reloaded.
+type ManagedIdentityCredentialSet interface {
+	// ForClientID selects the identity with the client ID.
+	ForClientID(clientID string) (azcore.TokenCredential, error)
+	// ForObjectID selects the identity with the object (principal) ID.
+	ForObjectID(objectID string) (azcore.TokenCredential, error)
+	// ForResourceID selects the user-assigned identity with the ARM resource ID.
+	ForResourceID(resourceID string) (azcore.TokenCredential, error)
+	// Close stops reloading the credentials file and waits for the background process to exit.
+	// Credentials selected from the set continue to serve the last certificates loaded.
+	io.Closer
+}
+
+type managedIdentityCredentialSet struct {
+	// template holds the options used to create the credential for each identity
+	template *reloadingCredential
+
+	lock       sync.RWMutex
+	identities map[string]*identityCredential
+}
+
+var _ ManagedIdentityCredentialSet = (*managedIdentityCredentialSet)(nil)
+
+// identityCredential is the reloading credential for one identity, indexed by its identifiers.
+type identityCredential struct {
+	// file is the name of the file holding the identity, for identities in a CredentialDirectory
+	file       string
+	clientID   string
+	objectID   string
+	resourceID string
+	credential *reloadingCredential
+}
+
+// sameIdentity determines if the other credential is for the same identity.
+func (i *identityCredential) sameIdentity(other *identityCredential) bool {
+	return i.clientID == other.clientID && i.objectID == other.objectID && i.resourceID == other.resourceID
+}
+
+// NewManagedIdentityCredentialSet creates a new ManagedIdentityCredentialSet from a file holding ManagedIdentityCredentials,
+// like the response from the MSI data plane when fetching credentials.
+// ctx is used to manage the lifecycle of the reloader, allowing for cancellation if reloading is no longer needed.
+// credentialPath is the path to the credential file.
+// opts allows for additional configuration, such as setting a custom logger, periodic reload time, and cloud environment.
+//
+// The function ensures that every identity in the file is valid before returning the set. It also starts a background
+// process to watch for changes to the credential file and reloads it as necessary, which every identity shares.
+func NewManagedIdentityCredentialSet(ctx context.Context, credentialPath string, opts ...Option) (ManagedIdentityCredentialSet, error) {
+	template, err := newReloadingCredential(6*time.Hour, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &managedIdentityCredentialSet{
+		template:   template,
+		identities: map[string]*identityCredential{},
+	}
+
+	// load once to validate everything before we return
+	if err := set.load(credentialPath); err != nil {
+		return nil, err
+	}
+	// start the process of watching - the caller can cancel ctx or close the set if they want to stop
+	if err := set.start(ctx, credentialPath); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (s *managedIdentityCredentialSet) ForClientID(clientID string) (azcore.TokenCredential, error) {
+	return selectIdentity(s, ErrIdentityNotInCredentials, "client ID", clientID, func(identity *identityCredential) string { return identity.clientID })
+}
+
+func (s *managedIdentityCredentialSet) ForObjectID(objectID string) (azcore.TokenCredential, error) {
+	return selectIdentity(s, ErrIdentityNotInCredentials, "object ID", objectID, func(identity *identityCredential) string { return identity.objectID })
+}
+
+func (s *managedIdentityCredentialSet) ForResourceID(resourceID string) (azcore.TokenCredential, error) {
+	return selectIdentity(s, ErrIdentityNotInCredentials, "resource ID", resourceID, func(identity *identityCredential) string { return identity.resourceID })
+}
+
+// identitySource holds the identities that selected credentials are resolved from.
+type identitySource interface {
+	// find returns the credential for the first identity that matches, or nil if none does.
+	find(matches func(*identityCredential) bool) (*reloadingCredential, error)
+	// defaults returns the credential whose options and health every identity shares.
+	defaults() *reloadingCredential
+}
+
+// selectIdentity selects the identity whose identifier matches the value, which must be present in the source.
+func selectIdentity(source identitySource, missing error, field, value string, identifier func(*identityCredential) string) (azcore.TokenCredential, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%w: %s", ErrEmptySelector, field)
+	}
+	selected := &selectedIdentityCredential{
+		source:  source,
+		missing: missing,
+		field:   field,
+		matches: func(identity *identityCredential) bool {
+			return strings.EqualFold(identifier(identity), value)
+		},
+		value: value,
+	}
+	if _, err := selected.resolve(); err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
+
+func (s *managedIdentityCredentialSet) defaults() *reloadingCredential {
+	return s.template
+}
+
+func (s *managedIdentityCredentialSet) find(matches func(*identityCredential) bool) (*reloadingCredential, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, identity := range s.identities {
+		if matches(identity) {
+			return identity.credential, nil
+		}
+	}
+	return nil, nil
+}
+
+// credentials lists the credential for every identity.
+func (s *managedIdentityCredentialSet) credentials() []*reloadingCredential {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	credentials := make([]*reloadingCredential, 0, len(s.identities))
+	for _, identity := range s.identities {
+		credentials = append(credentials, identity.credential)
+	}
+	return credentials
+}
+
+func (s *managedIdentityCredentialSet) start(ctx context.Context, credentialFile string) error {
+	// set up the file watcher, call load() when we see events or on some timer in case no events are delivered
+	fileWatcher, err := newCredentialFileWatcher(credentialFile, s.template.logger)
+	if err != nil {
+		return err
+	}
+	s.template.run(ctx, reloader{
+		watcher: fileWatcher.Watcher,
+		onEvent: func(event fsnotify.Event) error {
+			if !fileWatcher.shouldReload(event) {
+				return nil
+			}
+			return s.load(credentialFile)
+		},
+		reload: func(context.Context) error {
+			return s.load(credentialFile)
+		},
+		credentials: s.credentials,
+	})
+	return nil
+}
+
+func (s *managedIdentityCredentialSet) Close() error {
+	return s.template.Close()
+}
+
+func (s *managedIdentityCredentialSet) load(credentialFile string) (err error) {
+	defer func() { s.template.health.record(err) }()
+
+	// read the file from the filesystem
+	byteValue, err := os.ReadFile(credentialFile)
+	if err != nil {
+		return fmt.Errorf("failed to read credential file %s: %w", credentialFile, err)
+	}
+
+	var credentials ManagedIdentityCredentials
+	if err := s.template.decodeCredentials(byteValue, &credentials); err != nil {
+		return fmt.Errorf("failed to decode credential file %s: %w", credentialFile, err)
+	}
+
+	identities := containedIdentities(credentials)
+	if len(identities) == 0 {
+		return fmt.Errorf("credential file %s holds no identities", credentialFile)
+	}
+
+	var errs []error
+	updated := map[string]*identityCredential{}
+	for _, identity := range identities {
+		if identity.ClientID == nil {
+			errs = append(errs, fmt.Errorf("%w: clientID", errNilField))
+			continue
+		}
+		clientID := strings.ToLower(*identity.ClientID)
+
+		s.lock.RLock()
+		existing, loaded := s.identities[clientID]
+		s.lock.RUnlock()
+		credential := s.template.forIdentity()
+		if loaded {
+			credential = existing.credential
+		}
+		if err := credential.update(identity); err != nil {
+			errs = append(errs, fmt.Errorf("failed to load identity %s: %w", clientID, err))
+			if !loaded {
+				continue
+			}
+		}
+		updated[clientID] = &identityCredential{
+			clientID:   clientID,
+			objectID:   strings.ToLower(stringOrEmpty(identity.ObjectID)),
+			resourceID: strings.ToLower(stringOrEmpty(identity.ResourceID)),
+			credential: credential,
+		}
+	}
+
+	s.lock.Lock()
+	s.identities = updated
+	s.lock.Unlock()
+	return errors.Join(errs...)
+}
+
+// forIdentity creates a new credential for one identity, configured like this one.
+func (r *reloadingCredential) forIdentity() *reloadingCredential {
+	return &reloadingCredential{
+		clientOpts:    r.clientOpts,
+		lock:          &sync.RWMutex{},
+		logger:        r.logger,
+		cloud:         r.cloud,
+		clock:         r.clock,
+		skewTolerance: r.skewTolerance,
+		onChange:      r.onChange,
+		prefetch:      r.prefetch.forIdentity(),
+		// identities share the health of loading the file that holds them
+		health: r.health,
+	}
+}
+
+// containedIdentities lists every identity held in the credentials.
+func containedIdentities(credentials ManagedIdentityCredentials) []UserAssignedIdentityCredentials {
+	var identities []UserAssignedIdentityCredentials
+	if credentials.ClientSecret != nil {
+		identities = append(identities, UserAssignedIdentityCredentials{
+			AuthenticationEndpoint:     credentials.AuthenticationEndpoint,
+			CannotRenewAfter:           credentials.CannotRenewAfter,
+			ClientID:                   credentials.ClientID,
+			ClientSecret:               credentials.ClientSecret,
+			ClientSecretURL:            credentials.ClientSecretURL,
+			CustomClaims:               credentials.CustomClaims,
+			MtlsAuthenticationEndpoint: credentials.MtlsAuthenticationEndpoint,
+			NotAfter:                   credentials.NotAfter,
+			NotBefore:                  credentials.NotBefore,
+			ObjectID:                   credentials.ObjectID,
+			RenewAfter:                 credentials.RenewAfter,
+			TenantID:                   credentials.TenantID,
+		})
+	}
+	identities = append(identities, credentials.ExplicitIdentities...)
+	for _, delegatedResource := range credentials.DelegatedResources {
+		if delegatedResource.ImplicitIdentity != nil {
+			identities = append(identities, *delegatedResource.ImplicitIdentity)
+		}
+		identities = append(identities, delegatedResource.ExplicitIdentities...)
+	}
+	return identities
+}
+
+// selectedIdentityCredential finds the identity it was selected for every time a token is requested.
+type selectedIdentityCredential struct {
+	source identitySource
+	// missing is the error returned when the identity is not in the source
+	missing error
+	field   string
+	value   string
+	matches func(*identityCredential) bool
+}
+
+var _ azcore.TokenCredential = (*selectedIdentityCredential)(nil)
+
+func (c *selectedIdentityCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	credential, err := c.resolve()
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	return credential.GetToken(ctx, options)
+}
+
+func (c *selectedIdentityCredential) resolve() (*reloadingCredential, error) {
+	credential, err := c.source.find(c.matches)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, fmt.Errorf("%w: %s %s", c.missing, c.field, c.value)
+	}
+	return credential, nil
+}
+
+// Status reports on the certificate held for the selected identity and the outcome of the last attempt to load its source.
+func (c *selectedIdentityCredential) Status() CredentialStatus {
+	credential, err := c.resolve()
+	if err != nil {
+		status := c.source.defaults().health.status()
+		status.LastError = errors.Join(status.LastError, err)
+		return status
+	}
+	return credential.Status()
+}
@@ -0,0 +1,149 @@
+package dataplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/go-logr/logr"
+)
+
+func testManagedIdentityCredentials(t *testing.T, notBefore time.Time, withDelegatedResource bool) ManagedIdentityCredentials {
+	t.Helper()
+	identity := func(name string) UserAssignedIdentityCredentials {
+		return testIdentityCredentials(t, name, notBefore)
+	}
+
+	systemAssigned := testUserAssignedIdentityCredentials(t, notBefore)
+	credentials := ManagedIdentityCredentials{
+		AuthenticationEndpoint: systemAssigned.AuthenticationEndpoint,
+		CannotRenewAfter:       systemAssigned.CannotRenewAfter,
+		ClientID:               ptrTo("system-client"),
+		ClientSecret:           systemAssigned.ClientSecret,
+		NotAfter:               systemAssigned.NotAfter,
+		NotBefore:              systemAssigned.NotBefore,
+		ObjectID:               ptrTo("system-object"),
+		RenewAfter:             systemAssigned.RenewAfter,
+		TenantID:               systemAssigned.TenantID,
+		ExplicitIdentities:     []UserAssignedIdentityCredentials{identity("explicit")},
+	}
+	if withDelegatedResource {
+		credentials.DelegatedResources = []DelegatedResource{{
+			ImplicitIdentity:   ptrTo(identity("implicit")),
+			ExplicitIdentities: []UserAssignedIdentityCredentials{identity("delegated")},
+		}}
+	}
+	return credentials
+}
+
+func writeManagedIdentityCredentialsFile(t *testing.T, path string, credentials ManagedIdentityCredentials) {
+	t.Helper()
+	raw, err := json.Marshal(credentials)
+	if err != nil {
+		t.Fatalf("failed to marshal credentials: %v", err)
+	}
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, raw, 0600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		t.Fatalf("failed to rename credentials: %v", err)
+	}
+}
+
+func TestManagedIdentityCredentialSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	writeManagedIdentityCredentialsFile(t, path, testManagedIdentityCredentials(t, now.Add(-2*time.Hour), true))
+
+	logger := logr.Discard()
+	created, err := NewManagedIdentityCredentialSet(ctx, path, WithLogger(&logger))
+	if err != nil {
+		t.Fatalf("failed to create credential set: %v", err)
+	}
+	set := created.(*managedIdentityCredentialSet)
+
+	current := func(t *testing.T, selected azcore.TokenCredential) *reloadingCredential {
+		t.Helper()
+		credential, ok := selected.(*selectedIdentityCredential)
+		if !ok {
+			t.Fatalf("expected a selected identity credential, got %T", selected)
+		}
+		resolved, err := credential.resolve()
+		if err != nil {
+			t.Fatalf("failed to resolve credential: %v", err)
+		}
+		return resolved
+	}
+
+	selectors := map[string]struct {
+		selector func() (azcore.TokenCredential, error)
+		clientID string
+	}{
+		"system-assigned by client ID": {selector: func() (azcore.TokenCredential, error) { return set.ForClientID("SYSTEM-CLIENT") }, clientID: "system-client"},
+		"system-assigned by object ID": {selector: func() (azcore.TokenCredential, error) { return set.ForObjectID("system-object") }, clientID: "system-client"},
+		"explicit by resource ID": {selector: func() (azcore.TokenCredential, error) {
+			return set.ForResourceID("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/explicit")
+		}, clientID: "explicit-client"},
+		"implicit by client ID":  {selector: func() (azcore.TokenCredential, error) { return set.ForClientID("implicit-client") }, clientID: "implicit-client"},
+		"delegated by object ID": {selector: func() (azcore.TokenCredential, error) { return set.ForObjectID("delegated-object") }, clientID: "delegated-client"},
+	}
+	initial := map[string]*azidentity.ClientCertificateCredential{}
+	for name, testCase := range selectors {
+		selected, err := testCase.selector()
+		if err != nil {
+			t.Fatalf("%s: failed to select identity: %v", name, err)
+		}
+		credential := current(t, selected)
+		if expected := set.identities[testCase.clientID].credential; credential != expected {
+			t.Errorf("%s: selected the wrong identity", name)
+		}
+		initial[name] = credential.current()
+	}
+
+	if _, err := set.ForClientID("missing"); !errors.Is(err, ErrIdentityNotInCredentials) {
+		t.Errorf("expected a missing identity error, got %v", err)
+	}
+	for name, selector := range map[string]func(string) (azcore.TokenCredential, error){
+		"client ID":   set.ForClientID,
+		"object ID":   set.ForObjectID,
+		"resource ID": set.ForResourceID,
+	} {
+		if _, err := selector(""); !errors.Is(err, ErrEmptySelector) {
+			t.Errorf("expected an empty %s to be rejected, got %v", name, err)
+		}
+	}
+
+	t.Log("rotating the file reloads every identity from one watcher")
+	delegated, err := set.ForObjectID("delegated-object")
+	if err != nil {
+		t.Fatalf("failed to select identity: %v", err)
+	}
+	writeManagedIdentityCredentialsFile(t, path, testManagedIdentityCredentials(t, now.Add(-time.Hour), true))
+	waitFor(t, func() bool {
+		for name, testCase := range selectors {
+			selected, err := testCase.selector()
+			if err != nil || current(t, selected).current() == initial[name] {
+				return false
+			}
+		}
+		return true
+	})
+
+	t.Log("identities removed from the file can no longer be used")
+	writeManagedIdentityCredentialsFile(t, path, testManagedIdentityCredentials(t, now, false))
+	waitFor(t, func() bool {
+		_, err := delegated.GetToken(ctx, policy.TokenRequestOptions{})
+		return errors.Is(err, ErrIdentityNotInCredentials)
+	})
+}
@@ -0,0 +1,127 @@
+package dataplane
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+// newReloadingCredential creates a reloading credential with the defaults, reloading every backstop unless the
+// options say otherwise. Credentials for sets of identities use it as the template for each identity. It fails
+// when an option is invalid.
+func newReloadingCredential(backstop time.Duration, opts ...Option) (*reloadingCredential, error) {
+	defaultLog := logr.FromSlogHandler(slog.NewTextHandler(os.Stdout, nil))
+	credential := &reloadingCredential{
+		lock:          &sync.RWMutex{},
+		logger:        &defaultLog,
+		backstop:      backstop,
+		clock:         clock.Real,
+		skewTolerance: DefaultClockSkewTolerance,
+		health:        &loadHealth{},
+	}
+
+	for _, opt := range opts {
+		opt(credential)
+	}
+	if credential.optionErr != nil {
+		return nil, credential.optionErr
+	}
+	return credential, nil
+}
+
+// reloader describes how the background process reloads credentials from their source.
+type reloader struct {
+	// watcher, when set, delivers file system events to onEvent, otherwise the source is only reloaded periodically
+	watcher *fsnotify.Watcher
+	// onEvent reloads whatever the event may have changed
+	onEvent func(event fsnotify.Event) error
+	// reload reloads everything from the source, at the backstop interval
+	reload func(ctx context.Context) error
+	// credentials lists the credentials to prefetch tokens for
+	credentials func() []*reloadingCredential
+}
+
+// run starts the background process that reloads credentials with the reloader, until ctx is cancelled or the
+// credential is closed. The credential holds the options, health and lifecycle of the process. Tokens are prefetched
+// from a goroutine of their own, so a slow round-trip to Entra never holds up a reload, and Close waits for both.
+func (r *reloadingCredential) run(ctx context.Context, source reloader) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	cancel := r.cancel
+	r.done = make(chan struct{})
+	r.ticker = r.clock.NewTicker(r.backstop)
+	r.health.setWatching(true)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if source.watcher != nil {
+		events, errs = source.watcher.Events, source.watcher.Errors
+	}
+	go func() {
+		defer close(r.done)
+		var prefetching sync.WaitGroup
+		defer prefetching.Wait()
+		// the reloader may stop before ctx is done, when the watcher fails, and prefetching stops along with it
+		defer cancel()
+		defer r.health.setWatching(false)
+		if source.watcher != nil {
+			defer func() {
+				if err := source.watcher.Close(); err != nil {
+					r.logger.Error(err, "failed to close file watcher")
+				}
+			}()
+		}
+		defer r.ticker.Stop()
+
+		reloaded := func() {}
+		if r.prefetch != nil {
+			// reloads are coalesced while a prefetch is in flight, as it will see the latest credentials anyway
+			signal := make(chan struct{}, 1)
+			reloaded = func() {
+				select {
+				case signal <- struct{}{}:
+				default:
+				}
+			}
+			prefetching.Add(1)
+			go func() {
+				defer prefetching.Done()
+				prefetchUntilDone(ctx, r.clock, signal, source.credentials)
+			}()
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					r.logger.Info("stopping credential reloader since file watcher has no events")
+					return
+				}
+				if err := source.onEvent(event); err != nil {
+					r.logger.Error(err, "failed to reload credentials after file event")
+				}
+				reloaded()
+			case <-r.ticker.C():
+				if err := source.reload(ctx); err != nil {
+					r.logger.Error(err, "failed to reload credentials periodically")
+				}
+				reloaded()
+			case err, ok := <-errs:
+				if !ok {
+					r.logger.Info("stopping credential reloader since file watcher has no events")
+					return
+				}
+				r.logger.Error(err, "received an error from the file watcher")
+			case <-ctx.Done():
+				r.logger.Info("user signaled context cancel, stopping credential reloader")
+				return
+			}
+		}
+	}()
+}
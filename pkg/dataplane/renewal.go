@@ -0,0 +1,350 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+var (
+	errCannotRenew = errors.New("credentials can no longer be renewed")
+)
+
+// CredentialWriter persists credentials that have been formatted for storage, for instance
+// by FormatManagedIdentityCredentialsForStorage.
+type CredentialWriter interface {
+	// Put stores the credentials under the given name, replacing any previous value.
+	Put(ctx context.Context, name string, parameters azsecrets.SetSecretParameters) error
+}
+
+// RenewalTarget describes one set of managed identity credentials to keep renewed.
+type RenewalTarget struct {
+	// Identifier names the stored credentials, see FormatManagedIdentityCredentialsForStorage.
+	Identifier string
+
+	// IdentityURL is the URL from which credentials are renewed. Initially, this is the x-ms-identity-url
+	// header provided by ARM; after each renewal, the refreshed client_secret_url is used instead.
+	IdentityURL string
+
+	// UserAssignedIdentities, when set, renews credentials for user-assigned identities instead of the
+	// system-assigned identity. As with FormatManagedIdentityCredentialsForStorage, at most one explicit
+	// identity may be requested.
+	UserAssignedIdentities *UserAssignedIdentitiesRequest
+
+	// Credentials are the credentials currently held for the target, if any. When nil, credentials are
+	// fetched as soon as the controller runs.
+	Credentials *ManagedIdentityCredentials
+}
+
+// RenewalEventType classifies a RenewalEvent.
+type RenewalEventType string
+
+const (
+	// RenewalEventRenewed is emitted when credentials were renewed and persisted.
+	RenewalEventRenewed RenewalEventType = "Renewed"
+	// RenewalEventFailed is emitted when an attempt to renew or persist credentials failed. The attempt
+	// will be retried.
+	RenewalEventFailed RenewalEventType = "RenewalFailed"
+	// RenewalEventCannotRenewAfterApproaching is emitted when credentials have not been renewed and their
+	// CannotRenewAfter time is within the configured warning window.
+	RenewalEventCannotRenewAfterApproaching RenewalEventType = "CannotRenewAfterApproaching"
+	// RenewalEventCannotRenew is emitted when the CannotRenewAfter time of credentials has passed. No further
+	// attempts are made to renew them.
+	RenewalEventCannotRenew RenewalEventType = "CannotRenew"
+)
+
+// RenewalEvent describes a change in the renewal state of a target.
+type RenewalEvent struct {
+	Type RenewalEventType
+	// Identifier is the identifier of the target.
+	Identifier string
+	// RenewAfter and CannotRenewAfter are the renewal times of the credentials held for the target
+	// after the event. They are zero if no credentials are held.
+	RenewAfter       time.Time
+	CannotRenewAfter time.Time
+	// Err holds the cause of failure events.
+	Err error
+}
+
+// RenewalController renews managed identity credentials once their RenewAfter time passes and
+// persists the renewed credentials.
+type RenewalController interface {
+	// Run renews credentials until ctx is cancelled or none of the targets can be renewed any longer.
+	// An error is returned for every target whose CannotRenewAfter time passed before it was renewed.
+	Run(ctx context.Context) error
+}
+
+type renewalController struct {
+	factory ClientFactory
+	writer  CredentialWriter
+	targets []RenewalTarget
+
+	logger        *logr.Logger
+	onEvent       func(RenewalEvent)
+	jitter        time.Duration
+	concurrency   int
+	retryInterval time.Duration
+	warning       time.Duration
+	clock         clock.Clock
+}
+
+type RenewalControllerOption func(*renewalController)
+
+// WithRenewalLogger sets a custom logger for the RenewalController.
+func WithRenewalLogger(logger *logr.Logger) RenewalControllerOption {
+	return func(c *renewalController) {
+		c.logger = logger
+	}
+}
+
+// WithRenewalClock sets the clock used to schedule renewals, for instance to a clock.Fake in tests.
+func WithRenewalClock(clock clock.Clock) RenewalControllerOption {
+	return func(c *renewalController) {
+		c.clock = clock
+	}
+}
+
+// WithRenewalEventHandler registers a function to be called with every RenewalEvent. The handler is
+// called synchronously from the renewal loop of the target and should not block.
+func WithRenewalEventHandler(handler func(RenewalEvent)) RenewalControllerOption {
+	return func(c *renewalController) {
+		c.onEvent = handler
+	}
+}
+
+// WithRenewalJitter sets the upper bound of the random delay added after RenewAfter before a renewal
+// is attempted, so that credentials issued at the same time are not renewed at the same time.
+func WithRenewalJitter(jitter time.Duration) RenewalControllerOption {
+	return func(c *renewalController) {
+		c.jitter = jitter
+	}
+}
+
+// WithRenewalConcurrency sets the maximum number of renewals in flight at once.
+func WithRenewalConcurrency(concurrency int) RenewalControllerOption {
+	return func(c *renewalController) {
+		c.concurrency = concurrency
+	}
+}
+
+// WithRenewalRetryInterval sets the delay before a failed renewal is retried.
+func WithRenewalRetryInterval(interval time.Duration) RenewalControllerOption {
+	return func(c *renewalController) {
+		c.retryInterval = interval
+	}
+}
+
+// WithCannotRenewAfterWarning sets how long before the CannotRenewAfter time of credentials that have
+// not yet been renewed a RenewalEventCannotRenewAfterApproaching event is emitted.
+func WithCannotRenewAfterWarning(warning time.Duration) RenewalControllerOption {
+	return func(c *renewalController) {
+		c.warning = warning
+	}
+}
+
+// NewRenewalController creates a controller that uses clients from factory to renew credentials for each
+// of the targets, persisting the renewed credentials with writer.
+func NewRenewalController(factory ClientFactory, writer CredentialWriter, targets []RenewalTarget, opts ...RenewalControllerOption) RenewalController {
+	defaultLogger := logr.FromSlogHandler(slog.NewTextHandler(os.Stdout, nil))
+	controller := &renewalController{
+		factory:       factory,
+		writer:        writer,
+		targets:       targets,
+		logger:        &defaultLogger,
+		onEvent:       func(RenewalEvent) {},
+		jitter:        5 * time.Minute,
+		concurrency:   4,
+		retryInterval: time.Minute,
+		warning:       7 * 24 * time.Hour,
+		clock:         clock.Real,
+	}
+	for _, opt := range opts {
+		opt(controller)
+	}
+	return controller
+}
+
+func (c *renewalController) Run(ctx context.Context) error {
+	semaphore := make(chan struct{}, max(c.concurrency, 1))
+	errs := make([]error, len(c.targets))
+	wg := &sync.WaitGroup{}
+	for i, target := range c.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.renewUntilDone(ctx, target, semaphore)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// renewalState tracks the credentials held for one target.
+type renewalState struct {
+	target           RenewalTarget
+	renewAfter       time.Time
+	cannotRenewAfter time.Time
+	warned           bool
+}
+
+func (c *renewalController) renewUntilDone(ctx context.Context, target RenewalTarget, semaphore chan struct{}) error {
+	state := &renewalState{target: target}
+	next := c.clock.Now()
+	if target.Credentials != nil {
+		if err := state.update(*target.Credentials); err != nil {
+			return fmt.Errorf("%s: invalid credentials: %w", target.Identifier, err)
+		}
+		next = c.scheduleAfter(state.renewAfter)
+	}
+
+	for {
+		timer := c.clock.NewTimer(c.clock.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C():
+		}
+
+		now := c.clock.Now()
+		if !state.cannotRenewAfter.IsZero() {
+			if !now.Before(state.cannotRenewAfter) {
+				err := fmt.Errorf("%s: %w: cannot renew after %s", target.Identifier, errCannotRenew, state.cannotRenewAfter.Format(time.RFC3339))
+				c.emit(state, RenewalEventCannotRenew, err)
+				return err
+			}
+			if !state.warned && !now.Before(state.cannotRenewAfter.Add(-c.warning)) {
+				state.warned = true
+				c.emit(state, RenewalEventCannotRenewAfterApproaching, nil)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case semaphore <- struct{}{}:
+		}
+		err := c.renew(ctx, state)
+		<-semaphore
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			c.logger.Error(err, "failed to renew credentials", "identifier", target.Identifier)
+			c.emit(state, RenewalEventFailed, err)
+			next = c.clock.Now().Add(c.retryInterval)
+			if !state.cannotRenewAfter.IsZero() && next.After(state.cannotRenewAfter) {
+				next = state.cannotRenewAfter
+			}
+			continue
+		}
+
+		c.logger.Info("renewed credentials", "identifier", target.Identifier, "renewAfter", state.renewAfter)
+		c.emit(state, RenewalEventRenewed, nil)
+		next = c.scheduleAfter(state.renewAfter)
+	}
+}
+
+func (c *renewalController) renew(ctx context.Context, state *renewalState) error {
+	msiClient, err := c.factory.NewClient(state.target.IdentityURL)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	var credentials *ManagedIdentityCredentials
+	if state.target.UserAssignedIdentities != nil {
+		credentials, err = msiClient.GetUserAssignedIdentitiesCredentials(ctx, *state.target.UserAssignedIdentities)
+	} else {
+		credentials, err = msiClient.GetSystemAssignedIdentityCredentials(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	// credentials we could not schedule the next renewal for must not replace the stored ones
+	renewAfter, cannotRenewAfter, err := renewalTimes(*credentials)
+	if err != nil {
+		return fmt.Errorf("invalid renewed credentials: %w", err)
+	}
+	name, parameters, err := FormatManagedIdentityCredentialsForStorage(state.target.Identifier, *credentials)
+	if err != nil {
+		return fmt.Errorf("failed to format credentials for storage: %w", err)
+	}
+	if err := c.writer.Put(ctx, name, parameters); err != nil {
+		return fmt.Errorf("failed to store credentials: %w", err)
+	}
+
+	state.set(*credentials, renewAfter, cannotRenewAfter)
+	return nil
+}
+
+// renewalTimes parses the renewal times of credentials.
+func renewalTimes(credentials ManagedIdentityCredentials) (renewAfter, cannotRenewAfter time.Time, err error) {
+	_, _, rawRenewAfter, rawCannotRenewAfter, err := managedIdentityCredentialsTimestamps(credentials)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	for from, to := range map[*string]*time.Time{
+		rawRenewAfter:       &renewAfter,
+		rawCannotRenewAfter: &cannotRenewAfter,
+	} {
+		if from == nil {
+			return time.Time{}, time.Time{}, errors.New("assumption violated, renewal time was nil")
+		}
+		value, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		*to = value
+	}
+	return renewAfter, cannotRenewAfter, nil
+}
+
+// update records the renewal times of newly-held credentials.
+func (s *renewalState) update(credentials ManagedIdentityCredentials) error {
+	renewAfter, cannotRenewAfter, err := renewalTimes(credentials)
+	if err != nil {
+		return err
+	}
+	s.set(credentials, renewAfter, cannotRenewAfter)
+	return nil
+}
+
+// set records credentials with their renewal times, switching to the refreshed URL for future renewals.
+func (s *renewalState) set(credentials ManagedIdentityCredentials, renewAfter, cannotRenewAfter time.Time) {
+	s.renewAfter = renewAfter
+	s.cannotRenewAfter = cannotRenewAfter
+	s.warned = false
+	s.target.Credentials = &credentials
+	if credentials.ClientSecretURL != nil {
+		s.target.IdentityURL = *credentials.ClientSecretURL
+	}
+}
+
+func (c *renewalController) scheduleAfter(t time.Time) time.Time {
+	if c.jitter <= 0 {
+		return t
+	}
+	return t.Add(rand.N(c.jitter))
+}
+
+func (c *renewalController) emit(state *renewalState, eventType RenewalEventType, err error) {
+	c.onEvent(RenewalEvent{
+		Type:             eventType,
+		Identifier:       state.target.Identifier,
+		RenewAfter:       state.renewAfter,
+		CannotRenewAfter: state.cannotRenewAfter,
+		Err:              err,
+	})
+}
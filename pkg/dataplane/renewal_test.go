@@ -0,0 +1,264 @@
+package dataplane_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane"
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+	"github.com/Azure/msi-dataplane/pkg/dataplane/dataplanetest"
+)
+
+type fakeTokenCredential struct{}
+
+func (fakeTokenCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fake-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+type recordingWriter struct {
+	lock  sync.Mutex
+	names []string
+}
+
+func (w *recordingWriter) Put(_ context.Context, name string, _ azsecrets.SetSecretParameters) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.names = append(w.names, name)
+	return nil
+}
+
+func TestRenewalController(t *testing.T) {
+	times := dataplanetest.CredentialTimes{
+		NotBefore:        time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+		NotAfter:         time.Now().Add(90 * 24 * time.Hour).UTC().Truncate(time.Second),
+		RenewAfter:       time.Now().Add(46 * 24 * time.Hour).UTC().Truncate(time.Second),
+		CannotRenewAfter: time.Now().Add(90 * 24 * time.Hour).UTC().Truncate(time.Second),
+	}
+	server := dataplanetest.NewServer(dataplanetest.WithCredentialTimes(func() dataplanetest.CredentialTimes { return times }))
+	defer server.Close()
+
+	logger := logr.Discard()
+	factory := dataplane.NewClientFactory(fakeTokenCredential{}, "test-audience", server.ClientOptions(), dataplane.WithClientLogger(&logger))
+
+	for _, testCase := range []struct {
+		name     string
+		faults   []dataplanetest.Fault
+		target   dataplane.RenewalTarget
+		events   []dataplane.RenewalEventType
+		stored   []string
+		runError bool
+	}{
+		{
+			name: "renews credentials that were never fetched",
+			target: dataplane.RenewalTarget{
+				Identifier:  "first",
+				IdentityURL: server.IdentityURL(),
+			},
+			events: []dataplane.RenewalEventType{dataplane.RenewalEventRenewed},
+			stored: []string{"msi-first"},
+		},
+		{
+			name: "renews credentials past their renew_after time",
+			target: dataplane.RenewalTarget{
+				Identifier:  "second",
+				IdentityURL: server.IdentityURL(),
+				Credentials: &dataplane.ManagedIdentityCredentials{
+					RenewAfter:       ptrTo(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)),
+					CannotRenewAfter: ptrTo(time.Now().Add(time.Hour).UTC().Format(time.RFC3339)),
+				},
+			},
+			events: []dataplane.RenewalEventType{dataplane.RenewalEventCannotRenewAfterApproaching, dataplane.RenewalEventRenewed},
+			stored: []string{"msi-second"},
+		},
+		{
+			name:   "retries failed renewals",
+			faults: []dataplanetest.Fault{{StatusCode: http.StatusForbidden, Code: "Forbidden"}},
+			target: dataplane.RenewalTarget{
+				Identifier:  "third",
+				IdentityURL: server.IdentityURL(),
+			},
+			events: []dataplane.RenewalEventType{dataplane.RenewalEventFailed, dataplane.RenewalEventRenewed},
+			stored: []string{"msi-third"},
+		},
+		{
+			name: "gives up on credentials past their cannot_renew_after time",
+			target: dataplane.RenewalTarget{
+				Identifier:  "fourth",
+				IdentityURL: server.IdentityURL(),
+				Credentials: &dataplane.ManagedIdentityCredentials{
+					RenewAfter:       ptrTo(time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)),
+					CannotRenewAfter: ptrTo(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)),
+				},
+			},
+			events:   []dataplane.RenewalEventType{dataplane.RenewalEventCannotRenew},
+			runError: true,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			server.InjectFaults(testCase.faults...)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var events []dataplane.RenewalEventType
+			writer := &recordingWriter{}
+			controller := dataplane.NewRenewalController(factory, writer, []dataplane.RenewalTarget{testCase.target},
+				dataplane.WithRenewalLogger(&logger),
+				dataplane.WithRenewalJitter(0),
+				dataplane.WithRenewalRetryInterval(10*time.Millisecond),
+				dataplane.WithRenewalEventHandler(func(event dataplane.RenewalEvent) {
+					if event.Identifier != testCase.target.Identifier {
+						t.Errorf("unexpected identifier %q in event", event.Identifier)
+					}
+					events = append(events, event.Type)
+					if event.Type == dataplane.RenewalEventRenewed {
+						if !event.RenewAfter.Equal(times.RenewAfter) {
+							t.Errorf("expected renew_after %s, got %s", times.RenewAfter, event.RenewAfter)
+						}
+						cancel()
+					}
+				}),
+			)
+
+			err := controller.Run(ctx)
+			if testCase.runError && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !testCase.runError && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				t.Fatalf("timed out waiting for renewal")
+			}
+			if diff := cmp.Diff(testCase.events, events); diff != "" {
+				t.Errorf("unexpected events (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(testCase.stored, writer.names); diff != "" {
+				t.Errorf("unexpected stored credentials (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenewalControllerWithFakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	times := func() dataplanetest.CredentialTimes {
+		now := fake.Now()
+		return dataplanetest.CredentialTimes{
+			NotBefore:        now,
+			NotAfter:         now.Add(90 * 24 * time.Hour),
+			RenewAfter:       now.Add(46 * 24 * time.Hour),
+			CannotRenewAfter: now.Add(90 * 24 * time.Hour),
+		}
+	}
+	server := dataplanetest.NewServer(dataplanetest.WithCredentialTimes(times))
+	defer server.Close()
+
+	logger := logr.Discard()
+	factory := dataplane.NewClientFactory(fakeTokenCredential{}, "test-audience", server.ClientOptions(), dataplane.WithClientLogger(&logger))
+	initial := times()
+	target := dataplane.RenewalTarget{
+		Identifier:  "first",
+		IdentityURL: server.IdentityURL(),
+		Credentials: &dataplane.ManagedIdentityCredentials{
+			RenewAfter:       ptrTo(initial.RenewAfter.Format(time.RFC3339)),
+			CannotRenewAfter: ptrTo(initial.CannotRenewAfter.Format(time.RFC3339)),
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan dataplane.RenewalEvent, 1)
+	controller := dataplane.NewRenewalController(factory, &recordingWriter{}, []dataplane.RenewalTarget{target},
+		dataplane.WithRenewalLogger(&logger),
+		dataplane.WithRenewalJitter(0),
+		dataplane.WithRenewalClock(fake),
+		dataplane.WithRenewalEventHandler(func(event dataplane.RenewalEvent) { events <- event }),
+	)
+	done := make(chan error)
+	go func() { done <- controller.Run(ctx) }()
+
+	// a year of renewals, each once the credentials have been held for 46 days
+	for range 8 {
+		fake.BlockUntil(1)
+		fake.Step(46 * 24 * time.Hour)
+		select {
+		case event := <-events:
+			if event.Type != dataplane.RenewalEventRenewed {
+				t.Fatalf("expected credentials to be renewed, got %s: %v", event.Type, event.Err)
+			}
+			if expected := fake.Now().Add(46 * 24 * time.Hour); !event.RenewAfter.Equal(expected) {
+				t.Errorf("expected renew_after %s, got %s", expected, event.RenewAfter)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for renewal at %s", fake.Now())
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+// malformedClient returns credentials with a renew_after time that cannot be parsed.
+type malformedClient struct {
+	dataplane.Client
+}
+
+func (malformedClient) GetSystemAssignedIdentityCredentials(context.Context) (*dataplane.ManagedIdentityCredentials, error) {
+	return &dataplane.ManagedIdentityCredentials{
+		NotBefore:        ptrTo(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)),
+		NotAfter:         ptrTo(time.Now().Add(time.Hour).UTC().Format(time.RFC3339)),
+		RenewAfter:       ptrTo("soon"),
+		CannotRenewAfter: ptrTo(time.Now().Add(time.Hour).UTC().Format(time.RFC3339)),
+	}, nil
+}
+
+type malformedClientFactory struct{}
+
+func (malformedClientFactory) NewClient(string) (dataplane.Client, error) {
+	return malformedClient{}, nil
+}
+
+func TestRenewalControllerDoesNotStoreInvalidCredentials(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	logger := logr.Discard()
+	var events []dataplane.RenewalEventType
+	writer := &recordingWriter{}
+	controller := dataplane.NewRenewalController(malformedClientFactory{}, writer, []dataplane.RenewalTarget{{Identifier: "first", IdentityURL: "https://test.local/identity"}},
+		dataplane.WithRenewalLogger(&logger),
+		dataplane.WithRenewalJitter(0),
+		dataplane.WithRenewalEventHandler(func(event dataplane.RenewalEvent) {
+			events = append(events, event.Type)
+			cancel()
+		}),
+	)
+	if err := controller.Run(ctx); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Fatalf("timed out waiting for renewal")
+	}
+	if diff := cmp.Diff([]dataplane.RenewalEventType{dataplane.RenewalEventFailed}, events); diff != "" {
+		t.Errorf("unexpected events (-want +got):\n%s", diff)
+	}
+	if len(writer.names) != 0 {
+		t.Errorf("expected invalid credentials not to be stored, got %v", writer.names)
+	}
+}
+
+func ptrTo[o any](s o) *o {
+	return &s
+}
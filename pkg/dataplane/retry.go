@@ -0,0 +1,257 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+// RetryOptions configures how clients retry requests to the MSI data plane that are throttled or fail transiently.
+// Zero values are replaced with defaults.
+type RetryOptions struct {
+	// MaxRetries is the maximum number of times a request is retried. Defaults to three; a negative value disables retries.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, doubled for every subsequent retry. Defaults to one second.
+	RetryDelay time.Duration
+	// MaxRetryDelay caps the delay between retries, including delays requested by the service with a Retry-After
+	// header. Defaults to thirty seconds.
+	MaxRetryDelay time.Duration
+	// StatusCodes are the response codes for which requests are retried. Defaults to 408, 429, 500, 502, 503 and 504.
+	StatusCodes []int
+	// Budget is the number of retries that clients from one factory may make in a burst. Every request that does
+	// not need to be retried earns back a tenth of a retry, up to Budget. Once the budget is spent, failed responses
+	// are returned to the caller without retrying. Defaults to twenty.
+	Budget int
+	// RetryMoveIdentity allows MoveIdentity to be retried. Moving an identity is not idempotent, so by default
+	// requests to move identities are never retried.
+	RetryMoveIdentity bool
+}
+
+const (
+	defaultMaxRetries    = 3
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = 30 * time.Second
+	defaultRetryBudget   = 20
+)
+
+var defaultRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// WithRetryOptions configures the retry policy for clients created by the factory. Retries are handled by this
+// policy alone, so any retry options on the *azcore.ClientOptions passed to NewClientFactory are ignored.
+func WithRetryOptions(options RetryOptions) ClientFactoryOption {
+	return func(c *clientOpts) {
+		c.retryOptions = options
+	}
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = defaultMaxRetries
+	} else if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = defaultRetryDelay
+	}
+	if o.MaxRetryDelay <= 0 {
+		o.MaxRetryDelay = defaultMaxRetryDelay
+	}
+	if len(o.StatusCodes) == 0 {
+		o.StatusCodes = defaultRetryStatusCodes
+	}
+	if o.Budget <= 0 {
+		o.Budget = defaultRetryBudget
+	}
+	return o
+}
+
+// retryBudget limits retries across all the clients of a factory, so that a throttled service is not
+// hammered with retries for every identity at once. Tokens are counted in tenths of a retry.
+type retryBudget struct {
+	lock   sync.Mutex
+	tokens int
+	max    int
+}
+
+const (
+	retryCost         = 10
+	retryBudgetRefill = 1
+)
+
+func newRetryBudget(size int) *retryBudget {
+	return &retryBudget{tokens: size * retryCost, max: size * retryCost}
+}
+
+// withdraw spends a retry from the budget, if one is available.
+func (b *retryBudget) withdraw() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.tokens < retryCost {
+		return false
+	}
+	b.tokens -= retryCost
+	return true
+}
+
+// deposit earns back part of a retry after a request that did not need retrying.
+func (b *retryBudget) deposit() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.tokens = min(b.max, b.tokens+retryBudgetRefill)
+}
+
+type nonIdempotentKey struct{}
+
+// withNonIdempotent marks the requests made with the context as unsafe to retry.
+func withNonIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nonIdempotentKey{}, true)
+}
+
+func isNonIdempotent(ctx context.Context) bool {
+	nonIdempotent, ok := ctx.Value(nonIdempotentKey{}).(bool)
+	return ok && nonIdempotent
+}
+
+type retryPolicy struct {
+	options RetryOptions
+	budget  *retryBudget
+	logger  *logr.Logger
+	clock   clock.Clock
+}
+
+func newRetryPolicy(options RetryOptions, budget *retryBudget, logger *logr.Logger, clock clock.Clock) policy.Policy {
+	return &retryPolicy{
+		options: options,
+		budget:  budget,
+		logger:  logger,
+		clock:   clock,
+	}
+}
+
+func (p *retryPolicy) Do(req *policy.Request) (*http.Response, error) {
+	ctx := req.Raw().Context()
+	maxRetries := p.options.MaxRetries
+	if isNonIdempotent(ctx) && !p.options.RetryMoveIdentity {
+		maxRetries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := req.RewindBody(); err != nil {
+			return nil, err
+		}
+		// clone the request so that the rest of the pipeline runs afresh for every attempt
+		resp, err := req.Clone(ctx).Next()
+		if !p.shouldRetry(ctx, resp, err) {
+			p.budget.deposit()
+			return resp, err
+		}
+		if attempt >= maxRetries {
+			return resp, err
+		}
+		delay := p.delay(attempt, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// the caller would give up before we could retry, so hand them what we have now; deadlines are
+			// set on the wall clock, whichever clock we wait on
+			return resp, err
+		}
+		if !p.budget.withdraw() {
+			return resp, err
+		}
+
+		logger := p.logger.WithValues("attempt", attempt+1, "delay", delay.String())
+		if resp != nil {
+			logger.Info("retrying MSI data plane request", "statusCode", resp.StatusCode)
+			// we're done with this response, so release the connection before we retry
+			runtime.Drain(resp)
+		} else {
+			logger.Info("retrying MSI data plane request", "error", err.Error())
+		}
+
+		if delay <= 0 {
+			continue
+		}
+		timer := p.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *retryPolicy) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// errors from the context mean the caller has given up, and errors from other policies can't be fixed by retrying
+		var nonRetriable nonRetriableError
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+			!errors.As(err, &nonRetriable)
+	}
+	return slices.Contains(p.options.StatusCodes, resp.StatusCode)
+}
+
+// nonRetriableError matches errors that azcore policies mark as not worth retrying, like credential failures.
+type nonRetriableError interface {
+	error
+	NonRetriable()
+}
+
+// delay determines how long to wait before the next attempt, preferring what the service asked for, up to
+// the maximum delay.
+func (p *retryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if retryAfter, ok := retryAfter(resp, p.clock.Now()); ok {
+		return min(retryAfter, p.options.MaxRetryDelay)
+	}
+	delay := p.options.RetryDelay << attempt
+	if delay <= 0 || delay > p.options.MaxRetryDelay {
+		delay = p.options.MaxRetryDelay
+	}
+	// wait for somewhere between half the delay and the full delay, so that clients throttled
+	// at the same time don't all come back at the same time
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// retryAfter determines the delay requested by the service, preferring millisecond precision when available.
+// A delay of zero asks for the request to be retried right away.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	for _, header := range []string{"retry-after-ms", "x-ms-retry-after-ms"} {
+		if value := resp.Header.Get(header); value != "" {
+			if milliseconds, err := strconv.Atoi(value); err == nil && milliseconds >= 0 {
+				return time.Duration(milliseconds) * time.Millisecond, true
+			}
+		}
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
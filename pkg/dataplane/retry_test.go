@@ -0,0 +1,314 @@
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+// scriptedTransport replies with the scripted outcomes in order, repeating the last one when it runs out.
+type scriptedTransport struct {
+	lock     sync.Mutex
+	outcomes []scriptedOutcome
+	bodies   []string
+}
+
+type scriptedOutcome struct {
+	statusCode int
+	header     http.Header
+	err        error
+}
+
+func (s *scriptedTransport) Do(req *http.Request) (*http.Response, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+	s.bodies = append(s.bodies, string(body))
+	outcome := s.outcomes[min(len(s.bodies), len(s.outcomes))-1]
+	if outcome.err != nil {
+		return nil, outcome.err
+	}
+	header := outcome.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: outcome.statusCode, Header: header, Body: http.NoBody, Request: req}, nil
+}
+
+func (s *scriptedTransport) requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	fastRetries := RetryOptions{RetryDelay: time.Millisecond, MaxRetryDelay: 5 * time.Millisecond}
+
+	for _, testCase := range []struct {
+		name          string
+		options       RetryOptions
+		nonIdempotent bool
+		outcomes      []scriptedOutcome
+		expectedCalls int
+		expectedCode  int
+		expectedErr   bool
+	}{
+		{
+			name:          "success is not retried",
+			options:       fastRetries,
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusOK}},
+			expectedCalls: 1,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "client errors are not retried",
+			options:       fastRetries,
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusNotFound}},
+			expectedCalls: 1,
+			expectedCode:  http.StatusNotFound,
+		},
+		{
+			name:          "service unavailable is retried",
+			options:       fastRetries,
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}, {statusCode: http.StatusOK}},
+			expectedCalls: 2,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "transport errors are retried",
+			options:       fastRetries,
+			outcomes:      []scriptedOutcome{{err: errors.New("connection reset")}, {statusCode: http.StatusOK}},
+			expectedCalls: 2,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name: "throttling honours retry-after",
+			// without honouring the header, we would wait for an hour
+			options: RetryOptions{RetryDelay: time.Hour, MaxRetryDelay: time.Hour},
+			outcomes: []scriptedOutcome{
+				{statusCode: http.StatusTooManyRequests, header: http.Header{"Retry-After-Ms": []string{"5"}}},
+				{statusCode: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"0"}, "X-Ms-Retry-After-Ms": []string{"1"}}},
+				{statusCode: http.StatusOK},
+			},
+			expectedCalls: 3,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:    "retry-after is capped at the maximum delay",
+			options: fastRetries,
+			outcomes: []scriptedOutcome{
+				{statusCode: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"3600"}}},
+				{statusCode: http.StatusOK},
+			},
+			expectedCalls: 2,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name: "retry-after of zero retries right away",
+			// backing off instead would take us past the deadline
+			options: RetryOptions{RetryDelay: time.Hour, MaxRetryDelay: time.Hour},
+			outcomes: []scriptedOutcome{
+				{statusCode: http.StatusServiceUnavailable, header: http.Header{"Retry-After": []string{"0"}}},
+				{statusCode: http.StatusOK},
+			},
+			expectedCalls: 2,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "delays past the deadline are not waited for",
+			options:       RetryOptions{RetryDelay: time.Hour, MaxRetryDelay: time.Hour},
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}, {statusCode: http.StatusOK}},
+			expectedCalls: 1,
+			expectedCode:  http.StatusServiceUnavailable,
+		},
+		{
+			name:          "retries are limited",
+			options:       RetryOptions{MaxRetries: 2, RetryDelay: time.Millisecond},
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusInternalServerError}},
+			expectedCalls: 3,
+			expectedCode:  http.StatusInternalServerError,
+		},
+		{
+			name:          "retries can be disabled",
+			options:       RetryOptions{MaxRetries: -1},
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusInternalServerError}},
+			expectedCalls: 1,
+			expectedCode:  http.StatusInternalServerError,
+		},
+		{
+			name:          "custom status codes",
+			options:       RetryOptions{RetryDelay: time.Millisecond, StatusCodes: []int{http.StatusConflict}},
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusConflict}, {statusCode: http.StatusServiceUnavailable}},
+			expectedCalls: 2,
+			expectedCode:  http.StatusServiceUnavailable,
+		},
+		{
+			name:          "non-idempotent requests are not retried",
+			options:       fastRetries,
+			nonIdempotent: true,
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}, {statusCode: http.StatusOK}},
+			expectedCalls: 1,
+			expectedCode:  http.StatusServiceUnavailable,
+		},
+		{
+			name:          "non-idempotent transport errors are not retried",
+			options:       fastRetries,
+			nonIdempotent: true,
+			outcomes:      []scriptedOutcome{{err: errors.New("connection reset")}, {statusCode: http.StatusOK}},
+			expectedCalls: 1,
+			expectedErr:   true,
+		},
+		{
+			name:          "non-idempotent requests are retried when opted in",
+			options:       RetryOptions{RetryDelay: time.Millisecond, RetryMoveIdentity: true},
+			nonIdempotent: true,
+			outcomes:      []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}, {statusCode: http.StatusOK}},
+			expectedCalls: 2,
+			expectedCode:  http.StatusOK,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			transport := &scriptedTransport{outcomes: testCase.outcomes}
+			options := testCase.options.withDefaults()
+			pipeline := newRetryTestPipeline(options, newRetryBudget(options.Budget), transport)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if testCase.nonIdempotent {
+				ctx = withNonIdempotent(ctx)
+			}
+			resp, err := doRetryTestRequest(ctx, t, pipeline)
+			if testCase.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", testCase.expectedErr, err)
+			}
+			if err == nil && resp.StatusCode != testCase.expectedCode {
+				t.Errorf("expected status %d, got %d", testCase.expectedCode, resp.StatusCode)
+			}
+
+			requests := transport.requests()
+			if len(requests) != testCase.expectedCalls {
+				t.Errorf("expected %d requests, got %d", testCase.expectedCalls, len(requests))
+			}
+			for i, body := range requests {
+				if body != "payload" {
+					t.Errorf("request %d: expected the body to be rewound, got %q", i, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBudget(t *testing.T) {
+	options := RetryOptions{MaxRetries: 3, RetryDelay: time.Millisecond, Budget: 2}.withDefaults()
+	budget := newRetryBudget(options.Budget)
+
+	// two clients sharing a budget that allows for two retries in total
+	first := &scriptedTransport{outcomes: []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}}}
+	second := &scriptedTransport{outcomes: []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}}}
+	for _, transport := range []*scriptedTransport{first, second} {
+		if _, err := doRetryTestRequest(context.Background(), t, newRetryTestPipeline(options, budget, transport)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got, want := len(first.requests())+len(second.requests()), 2+2; got != want {
+		t.Errorf("expected %d requests in total, got %d", want, got)
+	}
+
+	// successful requests earn back the budget
+	healthy := &scriptedTransport{outcomes: []scriptedOutcome{{statusCode: http.StatusOK}}}
+	for range 10 {
+		if _, err := doRetryTestRequest(context.Background(), t, newRetryTestPipeline(options, budget, healthy)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	recovered := &scriptedTransport{outcomes: []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}, {statusCode: http.StatusOK}}}
+	resp, err := doRetryTestRequest(context.Background(), t, newRetryTestPipeline(options, budget, recovered))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the request to be retried after the budget recovered, got status %d", resp.StatusCode)
+	}
+}
+
+func TestRetryPolicyCancellation(t *testing.T) {
+	options := RetryOptions{RetryDelay: time.Hour, MaxRetryDelay: time.Hour}.withDefaults()
+	transport := &scriptedTransport{outcomes: []scriptedOutcome{{statusCode: http.StatusServiceUnavailable}}}
+	pipeline := newRetryTestPipeline(options, newRetryBudget(options.Budget), transport)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := doRetryTestRequest(ctx, t, pipeline); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation to interrupt the retry, got %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+		found    bool
+	}{
+		{name: "none", header: http.Header{}},
+		{name: "seconds", header: http.Header{"Retry-After": []string{"7"}}, expected: 7 * time.Second, found: true},
+		{name: "zero", header: http.Header{"Retry-After": []string{"0"}}, expected: 0, found: true},
+		{name: "negative", header: http.Header{"Retry-After": []string{"-7"}}},
+		{name: "milliseconds", header: http.Header{"Retry-After-Ms": []string{"250"}}, expected: 250 * time.Millisecond, found: true},
+		{name: "milliseconds preferred", header: http.Header{"Retry-After": []string{"7"}, "X-Ms-Retry-After-Ms": []string{"250"}}, expected: 250 * time.Millisecond, found: true},
+		{name: "invalid", header: http.Header{"Retry-After": []string{"soon"}}},
+		{name: "date in the past", header: http.Header{"Retry-After": []string{"Mon, 02 Jan 2006 15:04:04 GMT"}}, expected: 0, found: true},
+		{name: "date in the future", header: http.Header{"Retry-After": []string{"Mon, 02 Jan 2006 15:05:05 GMT"}}, expected: time.Minute, found: true},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, found := retryAfter(&http.Response{Header: testCase.header}, time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC))
+			if found != testCase.found {
+				t.Errorf("expected found %v, got %v", testCase.found, found)
+			}
+			if diff := cmp.Diff(testCase.expected, got); diff != "" {
+				t.Errorf("unexpected delay (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func newRetryTestPipeline(options RetryOptions, budget *retryBudget, transport policy.Transporter) runtime.Pipeline {
+	logger := logr.Discard()
+	return runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{
+		PerCall: []policy.Policy{newRetryPolicy(options, budget, &logger, clock.Real)},
+	}, &policy.ClientOptions{
+		Transport: transport,
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	})
+}
+
+func doRetryTestRequest(ctx context.Context, t *testing.T, pipeline runtime.Pipeline) (*http.Response, error) {
+	t.Helper()
+	req, err := runtime.NewRequest(ctx, http.MethodPost, "https://msi.example.com/identity")
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if err := req.SetBody(streaming.NopCloser(bytes.NewReader([]byte("payload"))), "text/plain"); err != nil {
+		t.Fatalf("failed to set body: %v", err)
+	}
+	return pipeline.Do(req)
+}
@@ -0,0 +1,203 @@
+package dataplane
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+// CredentialMetadata describes the certificate held by a reloading credential.
+type CredentialMetadata struct {
+	ClientID         string
+	ObjectID         string
+	ResourceID       string
+	TenantID         string
+	NotBefore        time.Time
+	NotAfter         time.Time
+	RenewAfter       time.Time
+	CannotRenewAfter time.Time
+}
+
+// CredentialStatus reports on the health of a reloading credential.
+type CredentialStatus struct {
+	// Current describes the certificate in use, or is nil when none has been loaded.
+	Current *CredentialMetadata
+	// LastLoaded is the last time a new certificate was loaded and taken into use. Reloading the certificate
+	// already held, or an older one, does not change it.
+	LastLoaded time.Time
+	// LastError is the error from the most recent attempt to load credentials, or nil if it succeeded. Loading a
+	// certificate older than the one held fails with ErrCredentialRolledBack.
+	LastError error
+	// Watching is true while the credential watches for changes to its source.
+	Watching bool
+}
+
+// CredentialStatusReporter is implemented by the reloading credentials in this package, such as those returned
+// from NewUserAssignedIdentityCredential, NewUserAssignedIdentityCredentialFromKeyVault, or selected from a
+// ManagedIdentityCredentialSet or CredentialDirectory.
+type CredentialStatusReporter interface {
+	Status() CredentialStatus
+}
+
+// loadHealth records the outcome of loading credentials, for status reporting.
+type loadHealth struct {
+	lock       sync.RWMutex
+	lastLoaded time.Time
+	lastError  error
+	watching   bool
+}
+
+// record records the outcome of an attempt to load credentials.
+func (h *loadHealth) record(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.lastError = err
+}
+
+// swapped records that a new certificate was taken into use.
+func (h *loadHealth) swapped(now time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.lastLoaded = now
+}
+
+func (h *loadHealth) setWatching(watching bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.watching = watching
+}
+
+func (h *loadHealth) status() CredentialStatus {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return CredentialStatus{
+		LastLoaded: h.lastLoaded,
+		LastError:  h.lastError,
+		Watching:   h.watching,
+	}
+}
+
+// Status reports on the certificate currently held and the outcome of the last attempt to load credentials.
+func (r *reloadingCredential) Status() CredentialStatus {
+	status := r.health.status()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.currentValue != nil {
+		metadata := r.metadata
+		status.Current = &metadata
+	}
+	return status
+}
+
+// credentialMetadata parses the metadata for the credentials.
+func credentialMetadata(credentials UserAssignedIdentityCredentials) (CredentialMetadata, error) {
+	metadata := CredentialMetadata{
+		ClientID:   stringOrEmpty(credentials.ClientID),
+		ObjectID:   stringOrEmpty(credentials.ObjectID),
+		ResourceID: stringOrEmpty(credentials.ResourceID),
+		TenantID:   stringOrEmpty(credentials.TenantID),
+	}
+	for _, field := range []struct {
+		name string
+		raw  *string
+		into *time.Time
+	}{
+		{name: "not_before", raw: credentials.NotBefore, into: &metadata.NotBefore},
+		{name: "not_after", raw: credentials.NotAfter, into: &metadata.NotAfter},
+		{name: "renew_after", raw: credentials.RenewAfter, into: &metadata.RenewAfter},
+		{name: "cannot_renew_after", raw: credentials.CannotRenewAfter, into: &metadata.CannotRenewAfter},
+	} {
+		if field.raw == nil {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, *field.raw)
+		if err != nil {
+			return CredentialMetadata{}, fmt.Errorf("failed to parse %s for credential: %w", field.name, err)
+		}
+		*field.into = parsed
+	}
+	return metadata, nil
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// NewCredentialHealthHandler returns an http.Handler for liveness and readiness probes. It responds with
+// 200 OK while the credential holds a certificate that remains valid for at least expiryMargin, and with
+// 503 Service Unavailable otherwise. The body of the response describes the status of the credential.
+func NewCredentialHealthHandler(credential CredentialStatusReporter, expiryMargin time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		now := clock.Real.Now()
+		if clocked, ok := credential.(clockedReporter); ok {
+			now = clocked.now()
+		}
+		status := credential.Status()
+		health := credentialHealth{
+			Healthy:    true,
+			LastLoaded: timeOrNil(status.LastLoaded),
+			Watching:   status.Watching,
+		}
+		if status.LastError != nil {
+			health.LastError = status.LastError.Error()
+		}
+		switch {
+		case status.Current == nil:
+			health.Healthy = false
+			health.Reason = "no credential has been loaded"
+		case !status.Current.NotAfter.IsZero() && now.Add(expiryMargin).After(status.Current.NotAfter):
+			health.Healthy = false
+			health.Reason = fmt.Sprintf("credential expires at %s", status.Current.NotAfter.Format(time.RFC3339))
+		}
+		if status.Current != nil {
+			health.NotBefore = timeOrNil(status.Current.NotBefore)
+			health.NotAfter = timeOrNil(status.Current.NotAfter)
+			health.RenewAfter = timeOrNil(status.Current.RenewAfter)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !health.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(health)
+	})
+}
+
+// clockedReporter is implemented by credentials that tell the time with a clock other than the real one.
+type clockedReporter interface {
+	now() time.Time
+}
+
+func (r *reloadingCredential) now() time.Time {
+	return r.clock.Now()
+}
+
+func (c *selectedIdentityCredential) now() time.Time {
+	return c.source.defaults().clock.Now()
+}
+
+// credentialHealth is the body served by the health handler.
+type credentialHealth struct {
+	Healthy    bool       `json:"healthy"`
+	Reason     string     `json:"reason,omitempty"`
+	NotBefore  *time.Time `json:"notBefore,omitempty"`
+	NotAfter   *time.Time `json:"notAfter,omitempty"`
+	RenewAfter *time.Time `json:"renewAfter,omitempty"`
+	LastLoaded *time.Time `json:"lastLoaded,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+	Watching   bool       `json:"watching"`
+}
+
+func timeOrNil(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,262 @@
+package dataplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+func TestReloadingCredentialStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := logr.Discard()
+
+	path := filepath.Join(t.TempDir(), "credential.json")
+	credentials := testUserAssignedIdentityCredentials(t, time.Now().Add(-time.Hour))
+	writeCredentialsFile(t, path, credentials)
+	tokenCredential, err := NewUserAssignedIdentityCredential(ctx, path, WithLogger(&logger))
+	if err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	credential := tokenCredential.(*reloadingCredential)
+
+	status := credential.Status()
+	if status.LastError != nil {
+		t.Errorf("expected no error after loading, got %v", status.LastError)
+	}
+	if status.LastLoaded.IsZero() {
+		t.Errorf("expected the time of the last load to be recorded")
+	}
+	if !status.Watching {
+		t.Errorf("expected the credential to be watching")
+	}
+	expected, err := credentialMetadata(credentials)
+	if err != nil {
+		t.Fatalf("failed to parse metadata: %v", err)
+	}
+	if status.Current == nil {
+		t.Fatalf("expected metadata for the current credential")
+	}
+	if diff := cmp.Diff(expected, *status.Current); diff != "" {
+		t.Errorf("unexpected metadata (-want, +got):\n%s", diff)
+	}
+
+	// a broken file is reported, but we keep the credential we have
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+	waitFor(t, func() bool { return credential.Status().LastError != nil })
+	if credential.Status().Current == nil {
+		t.Errorf("expected the previous credential to be kept")
+	}
+
+	cancel()
+	waitFor(t, func() bool { return !credential.Status().Watching })
+}
+
+type fakeStatusReporter CredentialStatus
+
+func (f fakeStatusReporter) Status() CredentialStatus {
+	return CredentialStatus(f)
+}
+
+func TestNewCredentialHealthHandler(t *testing.T) {
+	now := time.Now()
+	for _, testCase := range []struct {
+		name           string
+		status         CredentialStatus
+		expiryMargin   time.Duration
+		expectedStatus int
+		expectedReason string
+	}{
+		{
+			name: "valid credential",
+			status: CredentialStatus{
+				Current:    &CredentialMetadata{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(24 * time.Hour)},
+				LastLoaded: now,
+				Watching:   true,
+			},
+			expiryMargin:   time.Hour,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "valid credential with a failed reload",
+			status: CredentialStatus{
+				Current:   &CredentialMetadata{NotAfter: now.Add(24 * time.Hour)},
+				LastError: errors.New("oops"),
+				Watching:  true,
+			},
+			expiryMargin:   time.Hour,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "credential expiring within the margin",
+			status: CredentialStatus{
+				Current: &CredentialMetadata{NotAfter: now.Add(30 * time.Minute)},
+			},
+			expiryMargin:   time.Hour,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReason: "credential expires at",
+		},
+		{
+			name: "no credential",
+			status: CredentialStatus{
+				LastError: errors.New("oops"),
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReason: "no credential has been loaded",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			NewCredentialHealthHandler(fakeStatusReporter(testCase.status), testCase.expiryMargin).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if recorder.Code != testCase.expectedStatus {
+				t.Errorf("expected status %d, got %d", testCase.expectedStatus, recorder.Code)
+			}
+			var health credentialHealth
+			if err := json.Unmarshal(recorder.Body.Bytes(), &health); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if health.Healthy != (testCase.expectedStatus == http.StatusOK) {
+				t.Errorf("unexpected health in body: %v", health.Healthy)
+			}
+			if !strings.HasPrefix(health.Reason, testCase.expectedReason) {
+				t.Errorf("expected reason to start with %q, got %q", testCase.expectedReason, health.Reason)
+			}
+			if testCase.status.LastError != nil && health.LastError != testCase.status.LastError.Error() {
+				t.Errorf("expected last error %q, got %q", testCase.status.LastError, health.LastError)
+			}
+			if health.Watching != testCase.status.Watching {
+				t.Errorf("expected watching %v, got %v", testCase.status.Watching, health.Watching)
+			}
+		})
+	}
+}
+
+func TestReloadingCredentialChangeNotification(t *testing.T) {
+	logger := logr.Discard()
+	var changes []CredentialChange
+	credential := &reloadingCredential{lock: &sync.RWMutex{}, logger: &logger, clock: clock.Real, health: &loadHealth{}}
+	WithChangeNotification(func(change CredentialChange) {
+		// subscribers may inspect the credential while being notified
+		_ = credential.Status()
+		changes = append(changes, change)
+	})(credential)
+
+	now := time.Now()
+	first := testUserAssignedIdentityCredentials(t, now.Add(-time.Hour))
+	second := testUserAssignedIdentityCredentials(t, now)
+	older := testUserAssignedIdentityCredentials(t, now.Add(-2*time.Hour))
+	metadata := func(credentials UserAssignedIdentityCredentials) *CredentialMetadata {
+		parsed, err := credentialMetadata(credentials)
+		if err != nil {
+			t.Fatalf("failed to parse metadata: %v", err)
+		}
+		return &parsed
+	}
+
+	for _, credentials := range []UserAssignedIdentityCredentials{first, second, second} {
+		if err := credential.update(credentials); err != nil {
+			t.Fatalf("failed to update credential: %v", err)
+		}
+	}
+	if err := credential.update(older); !errors.Is(err, ErrCredentialRolledBack) {
+		t.Errorf("expected an older credential to be rejected, got %v", err)
+	}
+
+	expected := []CredentialChange{
+		{Current: *metadata(first)},
+		{Previous: metadata(first), Current: *metadata(second)},
+		// reloading the same certificate is not a change
+		{Previous: metadata(second), Current: *metadata(older), Rejected: true},
+	}
+	if diff := cmp.Diff(expected, changes); diff != "" {
+		t.Errorf("unexpected changes (-want, +got):\n%s", diff)
+	}
+}
+
+func TestReloadingCredentialStatusRecordsSwaps(t *testing.T) {
+	logger := logr.Discard()
+	fake := clock.NewFake(time.Now())
+	credential := &reloadingCredential{lock: &sync.RWMutex{}, logger: &logger, clock: fake, health: &loadHealth{}}
+
+	first := testUserAssignedIdentityCredentials(t, fake.Now().Add(-time.Hour))
+	if err := credential.update(first); err != nil {
+		t.Fatalf("failed to update credential: %v", err)
+	}
+	loaded := fake.Now()
+
+	for _, step := range []struct {
+		name        string
+		credentials UserAssignedIdentityCredentials
+		err         error
+	}{
+		{name: "the same certificate", credentials: first},
+		{name: "an older certificate", credentials: testUserAssignedIdentityCredentials(t, fake.Now().Add(-2*time.Hour)), err: ErrCredentialRolledBack},
+	} {
+		fake.Step(time.Minute)
+		err := credential.update(step.credentials)
+		credential.health.record(err)
+		status := credential.Status()
+		if !errors.Is(status.LastError, step.err) || (step.err == nil && status.LastError != nil) {
+			t.Errorf("%s: expected last error %v, got %v", step.name, step.err, status.LastError)
+		}
+		if !status.LastLoaded.Equal(loaded) {
+			t.Errorf("%s: expected the last load to stay at %s, got %s", step.name, loaded, status.LastLoaded)
+		}
+	}
+
+	fake.Step(time.Minute)
+	if err := credential.update(testUserAssignedIdentityCredentials(t, fake.Now())); err != nil {
+		t.Fatalf("failed to update credential: %v", err)
+	}
+	if status := credential.Status(); !status.LastLoaded.Equal(fake.Now()) {
+		t.Errorf("expected a newer certificate to be recorded as loaded at %s, got %s", fake.Now(), status.LastLoaded)
+	}
+}
+
+func TestCredentialHealthHandlerWithFakeClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := logr.Discard()
+
+	fake := clock.NewFake(time.Now())
+	path := filepath.Join(t.TempDir(), "credential.json")
+	writeCredentialsFile(t, path, testUserAssignedIdentityCredentials(t, fake.Now()))
+	credential, err := NewUserAssignedIdentityCredential(ctx, path, WithLogger(&logger), WithClock(fake))
+	if err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	handler := NewCredentialHealthHandler(credential.(CredentialStatusReporter), 24*time.Hour)
+
+	// the credential is valid for 90 days, so it stays healthy until a day before then
+	for _, step := range []struct {
+		after    time.Duration
+		expected int
+	}{
+		{after: 0, expected: http.StatusOK},
+		{after: 88 * 24 * time.Hour, expected: http.StatusOK},
+		{after: 24 * time.Hour, expected: http.StatusServiceUnavailable},
+	} {
+		fake.Step(step.after)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if recorder.Code != step.expected {
+			t.Errorf("expected status %d at %s, got %d", step.expected, fake.Now(), recorder.Code)
+		}
+	}
+}
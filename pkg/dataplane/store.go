@@ -0,0 +1,240 @@
+package dataplane
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+var (
+	// ErrCredentialNotFound is returned by a CredentialStore when no credentials are stored under a name.
+	ErrCredentialNotFound = errors.New("credentials not found")
+
+	errInvalidStorageName = errors.New("invalid name for stored credentials")
+
+	// storageNamePattern holds names to the constraints KeyVault places on secret names, so that
+	// every CredentialStore accepts the same names.
+	storageNamePattern = regexp.MustCompile(`^[0-9a-zA-Z-]{1,127}$`)
+)
+
+// CredentialStore persists credentials formatted for storage. Credentials are keyed by the names
+// returned from FormatManagedIdentityCredentialsForStorage and FormatUserAssignedIdentityCredentialsForStorage,
+// which carry the ManagedIdentityCredentialsStoragePrefix or UserAssignedIdentityCredentialsStoragePrefix.
+//
+// Every implementation records the expiry, not-before time and renewal tags of the credentials
+// in the same way that Azure KeyVault does, so stored credentials read back identically regardless
+// of where they were stored.
+type CredentialStore interface {
+	CredentialWriter
+
+	// Get retrieves the latest version of the credentials stored under name. If there are none,
+	// the error wraps ErrCredentialNotFound.
+	Get(ctx context.Context, name string) (azsecrets.Secret, error)
+
+	// List returns the names of all credentials in the store, ignoring any other items.
+	List(ctx context.Context) ([]string, error)
+
+	// Delete removes the credentials stored under name. If there are none, the error wraps
+	// ErrCredentialNotFound.
+	Delete(ctx context.Context, name string) error
+}
+
+type storeOpts struct {
+	clock clock.Clock
+}
+
+// CredentialStoreOption configures the in-memory and file stores, which record when credentials are stored themselves.
+type CredentialStoreOption func(*storeOpts)
+
+// WithStoreClock sets the clock used to record when credentials are stored, for instance to a clock.Fake in tests.
+func WithStoreClock(clock clock.Clock) CredentialStoreOption {
+	return func(o *storeOpts) {
+		o.clock = clock
+	}
+}
+
+func newStoreOpts(opts []CredentialStoreOption) storeOpts {
+	o := storeOpts{clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func validateStorageName(name string) error {
+	if !storageNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q must consist of 1-127 alphanumerics or dashes", errInvalidStorageName, name)
+	}
+	if !isStorageName(name) {
+		return fmt.Errorf("%w: %q must begin with %q or %q", errInvalidStorageName, name, ManagedIdentityCredentialsStoragePrefix, UserAssignedIdentityCredentialsStoragePrefix)
+	}
+	return nil
+}
+
+func isStorageName(name string) bool {
+	return strings.HasPrefix(name, ManagedIdentityCredentialsStoragePrefix) || strings.HasPrefix(name, UserAssignedIdentityCredentialsStoragePrefix)
+}
+
+// storedSecret records parameters the way KeyVault would, for stores that hold secrets themselves, as stored at now.
+// The scheme is used to form a secret ID that, like a KeyVault ID, encodes the name and version. The secret shares
+// no memory with the parameters.
+func storedSecret(now time.Time, scheme, name string, parameters azsecrets.SetSecretParameters) (azsecrets.Secret, error) {
+	if parameters.Value == nil {
+		return azsecrets.Secret{}, errors.New("assumption violated, value was nil")
+	}
+	version := make([]byte, 16)
+	if _, err := rand.Read(version); err != nil {
+		return azsecrets.Secret{}, fmt.Errorf("failed to generate version: %w", err)
+	}
+
+	now = now.UTC().Truncate(time.Second)
+	attributes := &azsecrets.SecretAttributes{
+		Enabled: ptrTo(true),
+		Created: ptrTo(now),
+		Updated: ptrTo(now),
+	}
+	if parameters.SecretAttributes != nil {
+		if parameters.SecretAttributes.Enabled != nil {
+			attributes.Enabled = ptrTo(*parameters.SecretAttributes.Enabled)
+		}
+		if parameters.SecretAttributes.Expires != nil {
+			attributes.Expires = ptrTo(parameters.SecretAttributes.Expires.UTC().Truncate(time.Second))
+		}
+		if parameters.SecretAttributes.NotBefore != nil {
+			attributes.NotBefore = ptrTo(parameters.SecretAttributes.NotBefore.UTC().Truncate(time.Second))
+		}
+	}
+
+	return azsecrets.Secret{
+		Attributes:  attributes,
+		ContentType: clonePtr(parameters.ContentType),
+		ID:          ptrTo(azsecrets.ID(fmt.Sprintf("%s:///secrets/%s/%s", scheme, name, hex.EncodeToString(version)))),
+		Tags:        cloneTags(parameters.Tags),
+		Value:       ptrTo(*parameters.Value),
+	}, nil
+}
+
+// cloneSecret copies the secret along with everything it points to.
+func cloneSecret(secret azsecrets.Secret) azsecrets.Secret {
+	clone := azsecrets.Secret{
+		ContentType: clonePtr(secret.ContentType),
+		ID:          clonePtr(secret.ID),
+		Tags:        cloneTags(secret.Tags),
+		Value:       clonePtr(secret.Value),
+		KID:         clonePtr(secret.KID),
+		Managed:     clonePtr(secret.Managed),
+	}
+	if secret.Attributes != nil {
+		clone.Attributes = &azsecrets.SecretAttributes{
+			Enabled:         clonePtr(secret.Attributes.Enabled),
+			Expires:         clonePtr(secret.Attributes.Expires),
+			NotBefore:       clonePtr(secret.Attributes.NotBefore),
+			Created:         clonePtr(secret.Attributes.Created),
+			RecoverableDays: clonePtr(secret.Attributes.RecoverableDays),
+			RecoveryLevel:   clonePtr(secret.Attributes.RecoveryLevel),
+			Updated:         clonePtr(secret.Attributes.Updated),
+		}
+	}
+	return clone
+}
+
+func cloneTags(tags map[string]*string) map[string]*string {
+	if tags == nil {
+		return nil
+	}
+	clone := make(map[string]*string, len(tags))
+	for key, value := range tags {
+		clone[key] = clonePtr(value)
+	}
+	return clone
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	return ptrTo(*p)
+}
+
+type inMemoryCredentialStore struct {
+	lock *sync.RWMutex
+	// secrets share no memory with callers, as Put and Get copy them, so that what is stored cannot change
+	secrets map[string]azsecrets.Secret
+	clock   clock.Clock
+}
+
+var _ CredentialStore = (*inMemoryCredentialStore)(nil)
+
+// NewInMemoryCredentialStore creates a CredentialStore that holds credentials in memory, for use in tests.
+func NewInMemoryCredentialStore(opts ...CredentialStoreOption) CredentialStore {
+	return &inMemoryCredentialStore{
+		lock:    &sync.RWMutex{},
+		secrets: map[string]azsecrets.Secret{},
+		clock:   newStoreOpts(opts).clock,
+	}
+}
+
+func (s *inMemoryCredentialStore) Put(_ context.Context, name string, parameters azsecrets.SetSecretParameters) error {
+	if err := validateStorageName(name); err != nil {
+		return err
+	}
+	secret, err := storedSecret(s.clock.Now(), "memory", name, parameters)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if previous, exists := s.secrets[name]; exists {
+		secret.Attributes.Created = clonePtr(previous.Attributes.Created)
+	}
+	s.secrets[name] = secret
+	return nil
+}
+
+func (s *inMemoryCredentialStore) Get(_ context.Context, name string) (azsecrets.Secret, error) {
+	if err := validateStorageName(name); err != nil {
+		return azsecrets.Secret{}, err
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	secret, exists := s.secrets[name]
+	if !exists {
+		return azsecrets.Secret{}, fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	return cloneSecret(secret), nil
+}
+
+func (s *inMemoryCredentialStore) List(_ context.Context) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	names := make([]string, 0, len(s.secrets))
+	for name := range s.secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *inMemoryCredentialStore) Delete(_ context.Context, name string) error {
+	if err := validateStorageName(name); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, exists := s.secrets[name]; !exists {
+		return fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	delete(s.secrets, name)
+	return nil
+}
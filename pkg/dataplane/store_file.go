@@ -0,0 +1,146 @@
+package dataplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+const storedCredentialFileExtension = ".json"
+
+type fileCredentialStore struct {
+	directory string
+	// lock serializes writes, so that the creation time carried over by Put is not lost to a concurrent write
+	lock  *sync.Mutex
+	clock clock.Clock
+}
+
+var _ CredentialStore = (*fileCredentialStore)(nil)
+
+// NewFileCredentialStore creates a CredentialStore that keeps each set of credentials in its own file
+// in directory, which must exist. Files are replaced atomically, so readers never observe partial writes.
+func NewFileCredentialStore(directory string, opts ...CredentialStoreOption) CredentialStore {
+	return &fileCredentialStore{directory: directory, lock: &sync.Mutex{}, clock: newStoreOpts(opts).clock}
+}
+
+func (s *fileCredentialStore) path(name string) string {
+	return filepath.Join(s.directory, name+storedCredentialFileExtension)
+}
+
+func (s *fileCredentialStore) Put(_ context.Context, name string, parameters azsecrets.SetSecretParameters) error {
+	if err := validateStorageName(name); err != nil {
+		return err
+	}
+	secret, err := storedSecret(s.clock.Now(), "file", name, parameters)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if previous, err := s.read(name); err == nil && previous.Attributes != nil {
+		secret.Attributes.Created = previous.Attributes.Created
+	}
+
+	raw, err := json.Marshal(secret)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	return writeFileAtomically(s.path(name), raw)
+}
+
+func (s *fileCredentialStore) Get(_ context.Context, name string) (azsecrets.Secret, error) {
+	if err := validateStorageName(name); err != nil {
+		return azsecrets.Secret{}, err
+	}
+	return s.read(name)
+}
+
+func (s *fileCredentialStore) read(name string) (azsecrets.Secret, error) {
+	raw, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return azsecrets.Secret{}, fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	if err != nil {
+		return azsecrets.Secret{}, fmt.Errorf("failed to read credentials %s: %w", name, err)
+	}
+	var secret azsecrets.Secret
+	if err := json.Unmarshal(raw, &secret); err != nil {
+		return azsecrets.Secret{}, fmt.Errorf("failed to unmarshal credentials %s: %w", name, err)
+	}
+	return secret, nil
+}
+
+func (s *fileCredentialStore) List(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		name, isCredentialFile := strings.CutSuffix(entry.Name(), storedCredentialFileExtension)
+		if !isCredentialFile || !entry.Type().IsRegular() || validateStorageName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *fileCredentialStore) Delete(_ context.Context, name string) error {
+	if err := validateStorageName(name); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := os.Remove(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete credentials %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeFileAtomically writes data to a temporary file next to path and renames it into place, so that
+// readers see either the previous content or the new content, never a partial write.
+func writeFileAtomically(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
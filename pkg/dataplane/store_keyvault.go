@@ -0,0 +1,96 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// secretsClient is the subset of *azsecrets.Client used to store credentials.
+type secretsClient interface {
+	SetSecret(ctx context.Context, name string, parameters azsecrets.SetSecretParameters, options *azsecrets.SetSecretOptions) (azsecrets.SetSecretResponse, error)
+	GetSecret(ctx context.Context, name string, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error)
+	DeleteSecret(ctx context.Context, name string, options *azsecrets.DeleteSecretOptions) (azsecrets.DeleteSecretResponse, error)
+	NewListSecretPropertiesPager(options *azsecrets.ListSecretPropertiesOptions) *runtime.Pager[azsecrets.ListSecretPropertiesResponse]
+}
+
+var _ secretsClient = (*azsecrets.Client)(nil)
+
+type keyVaultCredentialStore struct {
+	client secretsClient
+}
+
+var _ CredentialStore = (*keyVaultCredentialStore)(nil)
+
+// NewKeyVaultCredentialStore creates a CredentialStore that keeps credentials as secrets in Azure KeyVault.
+// Deleting credentials soft-deletes the secret; if the vault has soft-delete enabled, the name cannot be
+// reused until the deleted secret is purged or recovered.
+func NewKeyVaultCredentialStore(client *azsecrets.Client) CredentialStore {
+	return &keyVaultCredentialStore{client: client}
+}
+
+func (s *keyVaultCredentialStore) Put(ctx context.Context, name string, parameters azsecrets.SetSecretParameters) error {
+	if err := validateStorageName(name); err != nil {
+		return err
+	}
+	if _, err := s.client.SetSecret(ctx, name, parameters, nil); err != nil {
+		return fmt.Errorf("failed to set secret %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *keyVaultCredentialStore) Get(ctx context.Context, name string) (azsecrets.Secret, error) {
+	if err := validateStorageName(name); err != nil {
+		return azsecrets.Secret{}, err
+	}
+	resp, err := s.client.GetSecret(ctx, name, "", nil)
+	if err != nil {
+		return azsecrets.Secret{}, keyVaultError(name, "get", err)
+	}
+	return resp.Secret, nil
+}
+
+func (s *keyVaultCredentialStore) List(ctx context.Context) ([]string, error) {
+	var names []string
+	pager := s.client.NewListSecretPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		for _, properties := range page.Value {
+			if properties == nil || properties.ID == nil {
+				continue
+			}
+			if name := properties.ID.Name(); isStorageName(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *keyVaultCredentialStore) Delete(ctx context.Context, name string) error {
+	if err := validateStorageName(name); err != nil {
+		return err
+	}
+	if _, err := s.client.DeleteSecret(ctx, name, nil); err != nil {
+		return keyVaultError(name, "delete", err)
+	}
+	return nil
+}
+
+func keyVaultError(name, operation string, err error) error {
+	var responseErr *azcore.ResponseError
+	if errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s: %w", ErrCredentialNotFound, name, err)
+	}
+	return fmt.Errorf("failed to %s secret %s: %w", operation, name, err)
+}
@@ -0,0 +1,316 @@
+package dataplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/clock"
+)
+
+// fakeSecretsClient mimics the parts of KeyVault used by the credential store.
+type fakeSecretsClient struct {
+	secrets map[string]azsecrets.Secret
+}
+
+var _ secretsClient = (*fakeSecretsClient)(nil)
+
+func (f *fakeSecretsClient) SetSecret(_ context.Context, name string, parameters azsecrets.SetSecretParameters, _ *azsecrets.SetSecretOptions) (azsecrets.SetSecretResponse, error) {
+	secret, err := storedSecret(time.Now(), "https", name, parameters)
+	if err != nil {
+		return azsecrets.SetSecretResponse{}, err
+	}
+	f.secrets[name] = secret
+	return azsecrets.SetSecretResponse{Secret: secret}, nil
+}
+
+func (f *fakeSecretsClient) GetSecret(_ context.Context, name string, _ string, _ *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	secret, exists := f.secrets[name]
+	if !exists {
+		return azsecrets.GetSecretResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "SecretNotFound"}
+	}
+	return azsecrets.GetSecretResponse{Secret: secret}, nil
+}
+
+func (f *fakeSecretsClient) DeleteSecret(_ context.Context, name string, _ *azsecrets.DeleteSecretOptions) (azsecrets.DeleteSecretResponse, error) {
+	if _, exists := f.secrets[name]; !exists {
+		return azsecrets.DeleteSecretResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "SecretNotFound"}
+	}
+	delete(f.secrets, name)
+	return azsecrets.DeleteSecretResponse{}, nil
+}
+
+func (f *fakeSecretsClient) NewListSecretPropertiesPager(_ *azsecrets.ListSecretPropertiesOptions) *runtime.Pager[azsecrets.ListSecretPropertiesResponse] {
+	var properties []*azsecrets.SecretProperties
+	for _, secret := range f.secrets {
+		properties = append(properties, &azsecrets.SecretProperties{ID: secret.ID})
+	}
+	// a secret that was not written by this library should be ignored
+	properties = append(properties, &azsecrets.SecretProperties{ID: ptrTo(azsecrets.ID("https://vault/secrets/unrelated/version"))})
+	return runtime.NewPager(runtime.PagingHandler[azsecrets.ListSecretPropertiesResponse]{
+		More: func(azsecrets.ListSecretPropertiesResponse) bool { return false },
+		Fetcher: func(context.Context, *azsecrets.ListSecretPropertiesResponse) (azsecrets.ListSecretPropertiesResponse, error) {
+			return azsecrets.ListSecretPropertiesResponse{SecretPropertiesListResult: azsecrets.SecretPropertiesListResult{Value: properties}}, nil
+		},
+	})
+}
+
+func TestCredentialStores(t *testing.T) {
+	notBefore := time.Date(2001, 1, 2, 15, 4, 5, 0, time.UTC)
+	notAfter := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	managedIdentityName, managedIdentityParameters, err := FormatManagedIdentityCredentialsForStorage("test", ManagedIdentityCredentials{
+		ClientSecretURL:  ptrTo("whatever"),
+		CannotRenewAfter: ptrTo("2023-01-02T15:04:05Z"),
+		NotAfter:         ptrTo(notAfter.Format(time.RFC3339)),
+		NotBefore:        ptrTo(notBefore.Format(time.RFC3339)),
+		RenewAfter:       ptrTo("2003-01-02T15:04:05Z"),
+	})
+	if err != nil {
+		t.Fatalf("failed to format credentials: %v", err)
+	}
+	userAssignedName, userAssignedParameters, err := FormatUserAssignedIdentityCredentialsForStorage("test", UserAssignedIdentityCredentials{
+		ClientSecretURL:  ptrTo("whatever"),
+		CannotRenewAfter: ptrTo("2023-01-02T15:04:05Z"),
+		NotAfter:         ptrTo(notAfter.Format(time.RFC3339)),
+		NotBefore:        ptrTo(notBefore.Format(time.RFC3339)),
+		RenewAfter:       ptrTo("2003-01-02T15:04:05Z"),
+	})
+	if err != nil {
+		t.Fatalf("failed to format credentials: %v", err)
+	}
+
+	for _, testCase := range []struct {
+		name  string
+		store func(t *testing.T) CredentialStore
+	}{
+		{
+			name: "in-memory",
+			store: func(t *testing.T) CredentialStore {
+				return NewInMemoryCredentialStore()
+			},
+		},
+		{
+			name: "file",
+			store: func(t *testing.T) CredentialStore {
+				return NewFileCredentialStore(t.TempDir())
+			},
+		},
+		{
+			name: "keyvault",
+			store: func(t *testing.T) CredentialStore {
+				return &keyVaultCredentialStore{client: &fakeSecretsClient{secrets: map[string]azsecrets.Secret{}}}
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := testCase.store(t)
+
+			if err := store.Put(ctx, "not-credentials", managedIdentityParameters); !errors.Is(err, errInvalidStorageName) {
+				t.Errorf("expected an invalid name error, got %v", err)
+			}
+
+			for name, parameters := range map[string]azsecrets.SetSecretParameters{
+				managedIdentityName: managedIdentityParameters,
+				userAssignedName:    userAssignedParameters,
+			} {
+				if err := store.Put(ctx, name, parameters); err != nil {
+					t.Fatalf("failed to put %s: %v", name, err)
+				}
+				secret, err := store.Get(ctx, name)
+				if err != nil {
+					t.Fatalf("failed to get %s: %v", name, err)
+				}
+				if got := secret.ID.Name(); got != name {
+					t.Errorf("expected secret ID to name %q, got %q", name, got)
+				}
+				if diff := cmp.Diff(parameters.Value, secret.Value); diff != "" {
+					t.Errorf("unexpected value (-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(parameters.Tags, secret.Tags); diff != "" {
+					t.Errorf("unexpected tags (-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(parameters.SecretAttributes.Expires, secret.Attributes.Expires); diff != "" {
+					t.Errorf("unexpected expiry (-want +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(parameters.SecretAttributes.NotBefore, secret.Attributes.NotBefore); diff != "" {
+					t.Errorf("unexpected not-before (-want +got):\n%s", diff)
+				}
+			}
+
+			names, err := store.List(ctx)
+			if err != nil {
+				t.Fatalf("failed to list: %v", err)
+			}
+			if diff := cmp.Diff([]string{"msi-test", "uamsi-test"}, names); diff != "" {
+				t.Errorf("unexpected names (-want +got):\n%s", diff)
+			}
+
+			if err := store.Delete(ctx, managedIdentityName); err != nil {
+				t.Fatalf("failed to delete: %v", err)
+			}
+			if _, err := store.Get(ctx, managedIdentityName); !errors.Is(err, ErrCredentialNotFound) {
+				t.Errorf("expected a not found error after deletion, got %v", err)
+			}
+			if err := store.Delete(ctx, managedIdentityName); !errors.Is(err, ErrCredentialNotFound) {
+				t.Errorf("expected a not found error deleting twice, got %v", err)
+			}
+		})
+	}
+}
+
+// testStorageParameters formats managed identity credentials for storage.
+func testStorageParameters(t *testing.T) (string, azsecrets.SetSecretParameters) {
+	t.Helper()
+	name, parameters, err := FormatManagedIdentityCredentialsForStorage("test", ManagedIdentityCredentials{
+		ClientSecretURL:  ptrTo("whatever"),
+		CannotRenewAfter: ptrTo("2023-01-02T15:04:05Z"),
+		NotAfter:         ptrTo("2006-01-02T15:04:05Z"),
+		NotBefore:        ptrTo("2001-01-02T15:04:05Z"),
+		RenewAfter:       ptrTo("2003-01-02T15:04:05Z"),
+	})
+	if err != nil {
+		t.Fatalf("failed to format credentials: %v", err)
+	}
+	return name, parameters
+}
+
+func TestCredentialStoresRecordTimesWithClock(t *testing.T) {
+	for _, testCase := range []struct {
+		name  string
+		store func(t *testing.T, clock clock.Clock) CredentialStore
+	}{
+		{
+			name: "in-memory",
+			store: func(t *testing.T, clock clock.Clock) CredentialStore {
+				return NewInMemoryCredentialStore(WithStoreClock(clock))
+			},
+		},
+		{
+			name: "file",
+			store: func(t *testing.T, clock clock.Clock) CredentialStore {
+				return NewFileCredentialStore(t.TempDir(), WithStoreClock(clock))
+			},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx := context.Background()
+			created := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+			fake := clock.NewFake(created)
+			store := testCase.store(t, fake)
+			name, parameters := testStorageParameters(t)
+
+			if err := store.Put(ctx, name, parameters); err != nil {
+				t.Fatalf("failed to put %s: %v", name, err)
+			}
+			fake.Step(time.Hour)
+			if err := store.Put(ctx, name, parameters); err != nil {
+				t.Fatalf("failed to put %s: %v", name, err)
+			}
+			secret, err := store.Get(ctx, name)
+			if err != nil {
+				t.Fatalf("failed to get %s: %v", name, err)
+			}
+			if diff := cmp.Diff(&created, secret.Attributes.Created); diff != "" {
+				t.Errorf("unexpected creation time (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(ptrTo(created.Add(time.Hour)), secret.Attributes.Updated); diff != "" {
+				t.Errorf("unexpected update time (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInMemoryCredentialStoreCopies(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryCredentialStore()
+	name, parameters := testStorageParameters(t)
+	if err := store.Put(ctx, name, parameters); err != nil {
+		t.Fatalf("failed to put %s: %v", name, err)
+	}
+	expected, err := store.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+	expected = cloneSecret(expected)
+
+	// change everything the parameters and the secret read back point to
+	*parameters.Value = "changed"
+	*parameters.SecretAttributes.Expires = time.Time{}
+	for _, value := range parameters.Tags {
+		*value = "changed"
+	}
+	secret, err := store.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+	*secret.Value = "changed"
+	*secret.ID = "changed"
+	*secret.Attributes.Created = time.Time{}
+	*secret.Attributes.Expires = time.Time{}
+	for key, value := range secret.Tags {
+		*value = "changed"
+		secret.Tags[key+"-changed"] = value
+	}
+
+	secret, err = store.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+	if diff := cmp.Diff(expected, secret); diff != "" {
+		t.Errorf("expected the stored secret not to change (-want +got):\n%s", diff)
+	}
+}
+
+func TestFileCredentialStoreKeepsCreationTime(t *testing.T) {
+	ctx := context.Background()
+	name, parameters := testStorageParameters(t)
+
+	store := NewFileCredentialStore(t.TempDir()).(*fileCredentialStore)
+	if err := store.Put(ctx, name, parameters); err != nil {
+		t.Fatalf("failed to put %s: %v", name, err)
+	}
+	// backdate the creation time, so that a write which loses it cannot go unnoticed
+	created := time.Date(2001, 1, 2, 15, 4, 5, 0, time.UTC)
+	secret, err := store.read(name)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	secret.Attributes.Created = &created
+	raw, err := json.Marshal(secret)
+	if err != nil {
+		t.Fatalf("failed to marshal %s: %v", name, err)
+	}
+	if err := writeFileAtomically(store.path(name), raw); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+
+	wg := sync.WaitGroup{}
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.Put(ctx, name, parameters); err != nil {
+				t.Errorf("failed to put %s: %v", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	secret, err = store.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+	if diff := cmp.Diff(&created, secret.Attributes.Created); diff != "" {
+		t.Errorf("unexpected creation time (-want +got):\n%s", diff)
+	}
+}
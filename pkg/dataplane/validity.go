@@ -0,0 +1,87 @@
+package dataplane
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultClockSkewTolerance is how far the clock of the host may drift from the clock of the issuer before
+// credentials are judged to be expired or not yet valid, unless WithClockSkewTolerance is used.
+const DefaultClockSkewTolerance = 5 * time.Minute
+
+var (
+	// ErrCredentialExpired is returned when loading credentials whose validity has ended.
+	ErrCredentialExpired = errors.New("credential has expired")
+	// ErrCredentialNotYetValid is returned when loading credentials whose validity has yet to begin.
+	ErrCredentialNotYetValid = errors.New("credential is not yet valid")
+)
+
+// CredentialValidityError is returned when loading credentials outside of their validity window, either as stated
+// by the not_before and not_after fields of the payload or by the certificate in the client secret. It matches
+// ErrCredentialExpired or ErrCredentialNotYetValid with errors.Is.
+type CredentialValidityError struct {
+	// Subject is what was found invalid: the credential payload, or the certificate with the subject name.
+	Subject   string
+	NotBefore time.Time
+	NotAfter  time.Time
+	// Now is the time at which validity was judged.
+	Now time.Time
+
+	err error
+}
+
+func (e *CredentialValidityError) Error() string {
+	return fmt.Sprintf("%s: %s is valid from %s until %s, but it is %s", e.err, e.Subject,
+		e.NotBefore.Format(time.RFC3339), e.NotAfter.Format(time.RFC3339), e.Now.Format(time.RFC3339))
+}
+
+func (e *CredentialValidityError) Unwrap() error {
+	return e.err
+}
+
+// WithClockSkewTolerance sets how far the clock of the host may drift from the clock of the issuer before
+// credentials are judged to be expired or not yet valid.
+func WithClockSkewTolerance(tolerance time.Duration) Option {
+	return func(c *reloadingCredential) {
+		c.skewTolerance = tolerance
+	}
+}
+
+// validateCredentials ensures that both the credentials and their certificates are valid now.
+func (r *reloadingCredential) validateCredentials(credentials UserAssignedIdentityCredentials, metadata CredentialMetadata) error {
+	now := r.clock.Now()
+	if err := validateWindow("credential", metadata.NotBefore, metadata.NotAfter, now, r.skewTolerance); err != nil {
+		return err
+	}
+	if credentials.ClientSecret == nil {
+		// GetCredential reports the missing secret
+		return nil
+	}
+	certificates, _, err := parseClientSecret(*credentials.ClientSecret)
+	if err != nil {
+		return err
+	}
+	for _, certificate := range certificates {
+		subject := fmt.Sprintf("certificate %q", certificate.Subject.String())
+		if err := validateWindow(subject, certificate.NotBefore, certificate.NotAfter, now, r.skewTolerance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateWindow ensures that now falls between notBefore and notAfter, give or take the tolerance.
+// A zero bound is not checked.
+func validateWindow(subject string, notBefore, notAfter, now time.Time, tolerance time.Duration) error {
+	var err error
+	switch {
+	case !notBefore.IsZero() && now.Add(tolerance).Before(notBefore):
+		err = ErrCredentialNotYetValid
+	case !notAfter.IsZero() && !now.Add(-tolerance).Before(notAfter):
+		err = ErrCredentialExpired
+	default:
+		return nil
+	}
+	return &CredentialValidityError{Subject: subject, NotBefore: notBefore, NotAfter: notAfter, Now: now, err: err}
+}
@@ -0,0 +1,115 @@
+package dataplane
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/msi-dataplane/pkg/dataplane/internal/selfsigned"
+)
+
+func TestReloadingCredentialValidity(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	for _, testCase := range []struct {
+		name        string
+		credentials func(t *testing.T) UserAssignedIdentityCredentials
+		opts        []Option
+		expected    error
+		subject     string
+	}{
+		{
+			name: "valid",
+			credentials: func(t *testing.T) UserAssignedIdentityCredentials {
+				return testUserAssignedIdentityCredentials(t, now.Add(-day))
+			},
+		},
+		{
+			name: "expired",
+			credentials: func(t *testing.T) UserAssignedIdentityCredentials {
+				return testUserAssignedIdentityCredentials(t, now.Add(-91*day))
+			},
+			expected: ErrCredentialExpired,
+			subject:  "credential",
+		},
+		{
+			name: "not yet valid",
+			credentials: func(t *testing.T) UserAssignedIdentityCredentials {
+				return testUserAssignedIdentityCredentials(t, now.Add(time.Hour))
+			},
+			expected: ErrCredentialNotYetValid,
+			subject:  "credential",
+		},
+		{
+			name: "not yet valid within the default tolerance",
+			credentials: func(t *testing.T) UserAssignedIdentityCredentials {
+				return testUserAssignedIdentityCredentials(t, now.Add(time.Minute))
+			},
+		},
+		{
+			name: "not yet valid within a configured tolerance",
+			credentials: func(t *testing.T) UserAssignedIdentityCredentials {
+				return testUserAssignedIdentityCredentials(t, now.Add(time.Hour))
+			},
+			opts: []Option{WithClockSkewTolerance(2 * time.Hour)},
+		},
+		{
+			name: "not yet valid without tolerance",
+			credentials: func(t *testing.T) UserAssignedIdentityCredentials {
+				return testUserAssignedIdentityCredentials(t, now.Add(time.Minute))
+			},
+			opts:     []Option{WithClockSkewTolerance(0)},
+			expected: ErrCredentialNotYetValid,
+			subject:  "credential",
+		},
+		{
+			name: "expired certificate",
+			credentials: func(t *testing.T) UserAssignedIdentityCredentials {
+				credentials := testUserAssignedIdentityCredentials(t, now.Add(-day))
+				clientSecret, err := selfsigned.NewClientSecret("expired", now.Add(-2*day), now.Add(-day))
+				if err != nil {
+					t.Fatalf("failed to mint client secret: %v", err)
+				}
+				credentials.ClientSecret = &clientSecret
+				return credentials
+			},
+			expected: ErrCredentialExpired,
+			subject:  `certificate "CN=expired"`,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			logger := logr.Discard()
+
+			path := filepath.Join(t.TempDir(), "credential.json")
+			writeCredentialsFile(t, path, testCase.credentials(t))
+			credential, err := NewUserAssignedIdentityCredential(ctx, path, append([]Option{WithLogger(&logger)}, testCase.opts...)...)
+			if testCase.expected == nil {
+				if err != nil {
+					t.Fatalf("expected credentials to load, got %v", err)
+				}
+				if err := credential.(*reloadingCredential).Close(); err != nil {
+					t.Errorf("failed to close credential: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, testCase.expected) {
+				t.Fatalf("expected %v, got %v", testCase.expected, err)
+			}
+			var validityErr *CredentialValidityError
+			if !errors.As(err, &validityErr) {
+				t.Fatalf("expected a *CredentialValidityError, got %T", err)
+			}
+			if validityErr.Subject != testCase.subject {
+				t.Errorf("expected subject %s, got %s", testCase.subject, validityErr.Subject)
+			}
+		})
+	}
+}
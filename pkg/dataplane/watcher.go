@@ -0,0 +1,93 @@
+package dataplane
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// credentialFileWatcher watches a credential file for changes. Files mounted from Kubernetes secrets or CSI volumes
+// are updated by atomically swapping a symlink in the parent directory, which removes the file we were watching, so
+// we watch the parent directory as well as the file and track the target that the path resolves to.
+type credentialFileWatcher struct {
+	*fsnotify.Watcher
+	logger *logr.Logger
+
+	path string
+	dir  string
+	// resolved is the file that path resolved to, after following symlinks, when we last looked
+	resolved string
+}
+
+func newCredentialFileWatcher(path string, logger *logr.Logger) (*credentialFileWatcher, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine absolute path for credential file %s: %w", path, err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	w := &credentialFileWatcher{
+		Watcher: watcher,
+		logger:  logger,
+		path:    absolutePath,
+		dir:     filepath.Dir(absolutePath),
+	}
+	w.resolved, _ = filepath.EvalSymlinks(w.path)
+
+	// we close the file watcher if adding the directory to watch fails.
+	if err := w.Add(w.dir); err != nil {
+		if closeErr := w.Close(); closeErr != nil {
+			logger.Error(closeErr, "failed to close file watcher")
+		}
+		return nil, fmt.Errorf("failed to add credential directory to file watcher: %w", err)
+	}
+	// watching the file itself lets us see writes to the target of a symlink outside the directory
+	if err := w.Add(w.path); err != nil {
+		if closeErr := w.Close(); closeErr != nil {
+			logger.Error(closeErr, "failed to close file watcher")
+		}
+		return nil, fmt.Errorf("failed to add credential file to file watcher: %w", err)
+	}
+	return w, nil
+}
+
+// shouldReload determines if the event may have changed the content of the credential file.
+func (w *credentialFileWatcher) shouldReload(event fsnotify.Event) bool {
+	if filepath.Clean(event.Name) == w.path {
+		if event.Op.Has(fsnotify.Remove) || event.Op.Has(fsnotify.Rename) || event.Op.Has(fsnotify.Create) {
+			// the file we were watching is gone, so watch whatever replaced it
+			w.rewatchFile()
+		}
+		if event.Op.Has(fsnotify.Write) || event.Op.Has(fsnotify.Create) {
+			w.resolve()
+			return true
+		}
+	}
+	// any other change in the directory may have swapped a symlink along the path to the file
+	return w.resolve()
+}
+
+// resolve follows symlinks from the path, recording whether the file it points to has changed.
+func (w *credentialFileWatcher) resolve() bool {
+	resolved, err := filepath.EvalSymlinks(w.path)
+	if err != nil {
+		// the file is missing, possibly in the middle of a swap - we'll resolve it again on the next event
+		return false
+	}
+	changed := resolved != w.resolved
+	w.resolved = resolved
+	return changed
+}
+
+func (w *credentialFileWatcher) rewatchFile() {
+	// the watch may already be gone, in which case there's nothing to remove
+	_ = w.Remove(w.path)
+	if err := w.Add(w.path); err != nil {
+		// the file may not exist yet, we'll try again when it's created
+		w.logger.V(1).Info("failed to re-add credential file to file watcher", "path", w.path, "error", err.Error())
+	}
+}